@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+const (
+	accountPurgeInterval  = time.Hour
+	accountPurgeBatchSize = 100
+	dataExportTTL         = 48 * time.Hour
+)
+
+// dataExport is the archive of everything stored about a user. Secrets such as the
+// password hash and the tokens themselves are never part of it.
+type dataExport struct {
+	ExportedAt time.Time      `json:"exported_at"`
+	User       *data.User     `json:"user"`
+	Profile    *data.Profile  `json:"profile"`
+	Roles      []string       `json:"roles"`
+	Posts      []data.Blog    `json:"posts"`
+	Comments   []data.Comment `json:"comments"`
+	Sessions   []data.Session `json:"sessions"`
+	APIKeys    []*data.APIKey `json:"api_keys"`
+}
+
+// requestDataExportHandler assembles the data export in the background and emails a
+// link to download it once it is ready.
+func (app *application) requestDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.fullUser(r)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.background(func() {
+		err := app.sendDataExport(user)
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+	})
+	app.writeJSON(w, r, envelope{"message": "a download link will be emailed to you once your export is ready"}, http.StatusAccepted)
+}
+
+func (app *application) sendDataExport(user *data.User) error {
+	export := dataExport{ExportedAt: time.Now().UTC(), User: user}
+	var err error
+	if export.Profile, err = app.models.ProfileModel.Get(user.ID); err != nil {
+		return err
+	}
+	if export.Roles, err = app.models.RoleModel.GetAllForUser(user.ID); err != nil {
+		return err
+	}
+	if export.Posts, err = app.models.BlogModel.GetAllOwnedBy(user.ID); err != nil {
+		return err
+	}
+	if export.Comments, err = app.models.CommentModel.GetAllForUser(user.ID); err != nil {
+		return err
+	}
+	if export.Sessions, err = app.models.TokenModel.GetSessionsForUser(user.ID, 0); err != nil {
+		return err
+	}
+	if export.APIKeys, err = app.models.APIKeyModel.GetAllForUser(user.ID); err != nil {
+		return err
+	}
+	archive, err := json.MarshalIndent(export, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = app.models.DataExportModel.Insert(user.ID, archive, dataExportTTL)
+	if err != nil {
+		return err
+	}
+	err = app.models.TokenModel.DeleteAllForUser(data.ScopeDataExport, user.ID)
+	if err != nil {
+		return err
+	}
+	token, err := app.models.TokenModel.New(user.ID, dataExportTTL, data.ScopeDataExport)
+	if err != nil {
+		return err
+	}
+	downloadURL := app.config.baseURL + "/api/v1/exports/download?token=" + url.QueryEscape(token.Plaintext)
+	body := fmt.Sprintf("Your Goblog data export is ready. You can download it within the next %s from:\n\n%s",
+		dataExportTTL, downloadURL)
+	return app.mailer.Send(user.Email, "Your Goblog data export", body)
+}
+
+// downloadDataExportHandler serves the data export of the owner of the token in the
+// link that was emailed to them.
+func (app *application) downloadDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, token); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user, err := app.models.UserModel.GetForToken(data.ScopeDataExport, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			v.AddErrorMessage("token", "invalid or expired download token")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	archive, err := app.models.DataExportModel.Get(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="goblog-export.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// deleteAccountHandler schedules the account of the current user for deletion once the
+// grace period is over and signs it out everywhere. Signing in again is still possible
+// until then, so the deletion can be cancelled.
+//
+// The current password is required. Accounts created by signing in with an identity
+// provider have none anyone knows, so they set one with the password reset flow first,
+// which also proves control of the email.
+func (app *application) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+		Posts    string `json:"posts"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	user, err := app.fullUser(r)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	v := validator.New()
+	v.Check(input.Password != "", "password", "must be provided; if you sign in with an identity provider, set one with a password reset first")
+	v.Check(validator.In(input.Posts, "anonymize", "delete"), "posts", "must be either anonymize or delete")
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	if !app.checkPassword(w, r, user, input.Password, "password") {
+		return
+	}
+
+	deletion := &data.AccountDeletion{
+		UserID:      user.ID,
+		DeletePosts: input.Posts == "delete",
+		PurgeAt:     time.Now().Add(app.config.accounts.deletionGracePeriod),
+	}
+	err = app.models.AccountDeletionModel.Insert(deletion)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.signOut(user.ID, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.APIKeyModel.DeleteAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.background(func() {
+		body := fmt.Sprintf("Your Goblog account will be deleted on %s.\n\n"+
+			"If you change your mind, sign in before then and cancel the deletion.",
+			deletion.PurgeAt.UTC().Format(time.RFC1123))
+		err := app.mailer.Send(user.Email, "Your Goblog account is scheduled for deletion", body)
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+	})
+	app.writeJSON(w, r, envelope{"deletion": deletion}, http.StatusAccepted)
+}
+
+func (app *application) cancelAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	err := app.models.AccountDeletionModel.Delete(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+// runAccountPurges periodically deletes the accounts whose grace period is over, and
+// the data exports that expired.
+func (app *application) runAccountPurges() {
+	for {
+		err := app.purgeDueAccounts()
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+		time.Sleep(accountPurgeInterval)
+	}
+}
+
+func (app *application) purgeDueAccounts() error {
+	err := app.models.DataExportModel.DeleteExpired()
+	if err != nil {
+		return err
+	}
+	for {
+		deletions, err := app.models.AccountDeletionModel.GetDue(accountPurgeBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(deletions) == 0 {
+			return nil
+		}
+		for _, deletion := range deletions {
+			err := app.purgeAccount(deletion)
+			if err == nil {
+				continue
+			}
+			// A deletion that cannot be purged only waits for the next run itself, so the
+			// rest of the queue is not held up and this loop does not spin on it.
+			app.errorlog.Printf("purging the account of user %d: %v", deletion.UserID, err)
+			err = app.models.AccountDeletionModel.Postpone(deletion.UserID, time.Now().Add(accountPurgeInterval))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// purgeAccount deletes the account of deletion. Its access tokens are revoked and the
+// purge audited once the account is gone.
+func (app *application) purgeAccount(deletion data.AccountDeletion) error {
+	err := app.models.AccountDeletionModel.Purge(deletion)
+	if err != nil {
+		return err
+	}
+	err = app.revokeAccessTokens(deletion.UserID, 0)
+	if err != nil {
+		app.errorlog.Println(err)
+	}
+	userID := deletion.UserID
+	err = app.models.AuditLogModel.Insert(&data.AuditLog{
+		Action:       data.AuditUserPurged,
+		TargetUserID: &userID,
+		Details:      map[string]interface{}{"delete_posts": deletion.DeletePosts},
+	})
+	if err != nil {
+		app.errorlog.Println(err)
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+// adminPasswordResetTTL is longer than passwordResetTTL because the user did not ask
+// for the reset and may not read the email straight away.
+const adminPasswordResetTTL = 24 * time.Hour
+
+func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	filters := data.Filters{
+		Page:     app.readIntQuery(qs, "page", 1, v),
+		PageSize: app.readIntQuery(qs, "page_size", 20, v),
+	}
+	if data.ValidateFilters(v, filters); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	users, metadata, err := app.models.UserModel.GetAll(qs.Get("q"), filters)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"users": users, "metadata": metadata}, http.StatusOK)
+}
+
+func (app *application) showUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userForRequest(w, r)
+	if !ok {
+		return
+	}
+	posts, err := app.models.UserModel.PostCounts(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	follows, err := app.models.FollowModel.Counts(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	roles, err := app.models.RoleModel.GetAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"user": user, "posts": posts, "follows": follows, "roles": roles}, http.StatusOK)
+}
+
+func (app *application) updateUserActivationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userForRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Activated *bool `json:"activated"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+	v := validator.New()
+	v.Check(input.Activated != nil, "activated", "must be provided")
+	v.Check(user.ID != app.contextGetUser(r).ID, "user", "you cannot change the activation of your own account")
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	action := data.AuditUserActivated
+	if !*input.Activated {
+		action = data.AuditUserDeactivated
+	}
+	err = app.audit(r, action, user.ID, nil)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+
+	wasActivated := user.Activated
+	user.Activated = *input.Activated
+	err = app.models.UserModel.Update(user)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !user.Activated {
+		err = app.signOut(user.ID, 0)
+	} else {
+		err = app.revokeAccessTokens(user.ID, 0)
+	}
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if user.Activated && !wasActivated {
+		app.triggerWebhookEvent(user.ID, data.EventUserActivated, user)
+	}
+	app.writeJSON(w, r, envelope{"user": user}, http.StatusOK)
+}
+
+// forcePasswordResetHandler replaces the password of a user with an unusable one, signs
+// them out everywhere and emails them a password reset token to choose a new one.
+func (app *application) forcePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userForRequest(w, r)
+	if !ok {
+		return
+	}
+	err := app.audit(r, data.AuditUserPasswordReset, user.ID, nil)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = user.Password.SetUnusable()
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.UserModel.Update(user)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.TokenModel.RevokeAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.APIKeyModel.DeleteAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.revokeAccessTokens(user.ID, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.background(func() {
+		err := app.sendPasswordReset(user, adminPasswordResetTTL)
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+	})
+	app.writeJSON(w, r, envelope{"message": "password reset, all tokens and API keys revoked and a reset token emailed to the user"}, http.StatusOK)
+}
+
+func (app *application) revokeUserTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userForRequest(w, r)
+	if !ok {
+		return
+	}
+	err := app.audit(r, data.AuditUserTokensRevoked, user.ID, nil)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.TokenModel.RevokeAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.APIKeyModel.DeleteAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.revokeAccessTokens(user.ID, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userForRequest(w, r)
+	if !ok {
+		return
+	}
+	v := validator.New()
+	v.Check(user.ID != app.contextGetUser(r).ID, "user", "you cannot delete your own account")
+	// The deleted user owns the posts kept from purged accounts.
+	v.Check(user.Email != data.DeletedUserEmail, "user", "the placeholder for deleted accounts cannot be deleted")
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+	err := app.audit(r, data.AuditUserDeleted, user.ID, map[string]interface{}{"email": user.Email})
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	result, err := app.models.UserModel.Delete(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if result == 0 {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+	err = app.revokeAccessTokens(user.ID, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+func (app *application) listAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	filters := data.Filters{
+		Page:     app.readIntQuery(qs, "page", 1, v),
+		PageSize: app.readIntQuery(qs, "page_size", 20, v),
+	}
+	userID := app.readIntQuery(qs, "user_id", 0, v)
+	if data.ValidateFilters(v, filters); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	entries, metadata, err := app.models.AuditLogModel.GetAll(userID, filters)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"audit_log": entries, "metadata": metadata}, http.StatusOK)
+}
+
+func (app *application) showUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userForRequest(w, r)
+	if !ok {
+		return
+	}
+	roles, err := app.models.RoleModel.GetAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	permissions, err := app.models.PermissionModel.GetAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"roles": roles, "permissions": permissions}, http.StatusOK)
+}
+
+func (app *application) updateUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userForRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Roles []string `json:"roles"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Roles != nil, "roles", "must be provided")
+	data.ValidateRoles(v, input.Roles)
+	// Stops the last admin from locking everyone out by demoting themselves.
+	if user.ID == app.contextGetUser(r).ID {
+		v.Check(validator.In(data.RoleAdmin, input.Roles...), "roles", "you cannot remove your own admin role")
+	}
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	err = app.audit(r, data.AuditUserRolesUpdated, user.ID, map[string]interface{}{"roles": input.Roles})
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.RoleModel.SetForUser(user.ID, input.Roles)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	// Stateless access tokens carry the old permissions, refreshing picks up the new ones.
+	err = app.revokeAccessTokens(user.ID, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	permissions, err := app.models.PermissionModel.GetAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"roles": input.Roles, "permissions": permissions}, http.StatusOK)
+}
+
+// unlockUserHandler lifts the login lockout of a user and forgets their failed logins.
+// Lockouts of client IPs expire on their own.
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userForRequest(w, r)
+	if !ok {
+		return
+	}
+	err := app.audit(r, data.AuditUserUnlocked, user.ID, nil)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.LoginFailureModel.Delete(data.LoginFailureEmail, loginSubject(user.Email))
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+// userForRequest loads the user named by the :id parameter. It writes the error
+// response itself and returns false on failure.
+func (app *application) userForRequest(w http.ResponseWriter, r *http.Request) (*data.User, bool) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return nil, false
+	}
+	user, err := app.models.UserModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// audit records an admin action taken by the current user against targetUserID.
+// Handlers call it before making the change, so no change is made without an entry
+// in the audit log; a change that then fails leaves its entry behind.
+func (app *application) audit(r *http.Request, action string, targetUserID int, details map[string]interface{}) error {
+	actorID := app.contextGetUser(r).ID
+	return app.models.AuditLogModel.Insert(&data.AuditLog{
+		ActorID:      &actorID,
+		Action:       action,
+		TargetUserID: &targetUserID,
+		Details:      details,
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name   string     `json:"name"`
+		Scopes []string   `json:"scopes"`
+		Expiry *time.Time `json:"expiry"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	user := app.contextGetUser(r)
+	permissions, err := app.models.PermissionModel.GetAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	key, err := data.NewAPIKey(user.ID, input.Name, input.Scopes, input.Expiry)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateAPIKey(v, key, permissions); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	err = app.models.APIKeyModel.Insert(key)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	// Only the hash is stored, so this is the one time the key can be shown.
+	app.writeJSON(w, r, envelope{"api_key": key}, http.StatusCreated)
+}
+
+func (app *application) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	keys, err := app.models.APIKeyModel.GetAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"api_keys": keys}, http.StatusOK)
+}
+
+func (app *application) deleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return
+	}
+	user := app.contextGetUser(r)
+	err = app.models.APIKeyModel.Delete(user.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
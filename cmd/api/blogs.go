@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -14,6 +15,7 @@ func (app *application) createBlogHandler(w http.ResponseWriter, r *http.Request
 	var input struct {
 		Title   string `json:"title"`
 		Content string `json:"content"`
+		Locale  string `json:"locale"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -31,6 +33,10 @@ func (app *application) createBlogHandler(w http.ResponseWriter, r *http.Request
 		CreatedAt: time.Now(),
 		UserID:    user.ID,
 		Slug:      slug.Make(input.Title),
+		Locale:    data.NormalizeLocale(input.Locale),
+	}
+	if blog.Locale == "" {
+		blog.Locale = "en"
 	}
 
 	if data.ValidateBlog(v, blog); !v.IsValid() {
@@ -38,11 +44,20 @@ func (app *application) createBlogHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	profile, err := app.models.ProfileModel.Get(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
 	err = app.models.BlogModel.Insert(blog)
 	if err != nil {
 		app.internalServerErrorResponse(w, r, err.Error())
 		return
 	}
+	app.triggerWebhookEvent(user.ID, data.EventBlogCreated, blog)
+	app.publishBlogEvent(data.EventBlogCreated, blog)
+	app.sendNewsletter(blog)
+	app.notifyMentions(user.ID, blog.ID, blog.Content, fmt.Sprintf("%s mentioned you in the post %q", commentAuthorName(profile), blog.Title), 0)
 
 	app.writeJSON(w, r, envelope{"blog": blog}, http.StatusCreated)
 }
@@ -53,7 +68,18 @@ func (app *application) listBlogsHandler(w http.ResponseWriter, r *http.Request)
 		app.internalServerErrorResponse(w, r, err.Error())
 		return
 	}
-	app.writeJSON(w, r, envelope{"blogs": blogs}, http.StatusOK)
+	err = app.localizeBlogs(r, blogs)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	// No Last-Modified here: deleting a blog does not move the newest updated_at,
+	// so only the body hash can tell that the list changed.
+	err = app.writeJSONConditional(w, r, envelope{"blogs": blogs}, time.Time{})
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+	}
 }
 
 func (app *application) getBlogHandler(w http.ResponseWriter, r *http.Request) {
@@ -73,7 +99,39 @@ func (app *application) getBlogHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	app.writeJSON(w, r, envelope{"blog": blog}, http.StatusOK)
+	user := app.contextGetUser(r)
+	if blog.Hidden && blog.UserID != user.ID {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+	blogs := []data.Blog{*blog}
+	err = app.localizeBlogs(r, blogs)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	blog = &blogs[0]
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Add("Vary", "Authorization")
+	w.Header().Set("Content-Language", blog.Locale)
+
+	env := envelope{"blog": blog}
+	lastModified := blog.UpdatedAt
+	if !user.IsAnonymous() {
+		bookmarked, err := app.models.BookmarkModel.Exists(user.ID, blog.ID)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+		env["bookmarked"] = bookmarked
+		// Bookmarking does not move updated_at, so only the body hash can tell that
+		// a personalized response changed.
+		lastModified = time.Time{}
+	}
+	err = app.writeJSONConditional(w, r, env, lastModified)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+	}
 }
 
 func (app *application) deleteBlogHandler(w http.ResponseWriter, r *http.Request) {
@@ -82,7 +140,6 @@ func (app *application) deleteBlogHandler(w http.ResponseWriter, r *http.Request
 		app.badRequestErrorResponse(w, r, err.Error())
 		return
 	}
-	currentUser := app.contextGetUser(r)
 	blog, err := app.models.BlogModel.Get(id)
 	if err != nil {
 		switch {
@@ -94,7 +151,12 @@ func (app *application) deleteBlogHandler(w http.ResponseWriter, r *http.Request
 			return
 		}
 	}
-	if currentUser.ID != blog.UserID {
+	allowed, err := app.canEditBlog(r, blog)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !allowed {
 		app.unauthorizedErrorResponse(w, r)
 		return
 	}
@@ -108,6 +170,7 @@ func (app *application) deleteBlogHandler(w http.ResponseWriter, r *http.Request
 		app.notFoundErrorResponse(w, r)
 		return
 	}
+	app.triggerWebhookEvent(blog.UserID, data.EventBlogDeleted, envelope{"id": blog.ID})
 	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
 }
 
@@ -129,8 +192,12 @@ func (app *application) updateBlogHandler(w http.ResponseWriter, r *http.Request
 			return
 		}
 	}
-	currentUser := app.contextGetUser(r)
-	if currentUser.ID != blog.UserID {
+	allowed, err := app.canEditBlog(r, blog)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !allowed {
 		app.unauthorizedErrorResponse(w, r)
 		return
 	}
@@ -163,6 +230,54 @@ func (app *application) updateBlogHandler(w http.ResponseWriter, r *http.Request
 		app.internalServerErrorResponse(w, r, err.Error())
 		return
 	}
+	// A hidden post stays editable by its owner, but nobody else hears about it.
+	if !blog.Hidden {
+		app.triggerWebhookEvent(b.UserID, data.EventBlogUpdated, b)
+		app.publishBlogEvent(data.EventBlogUpdated, b)
+	}
 	app.writeJSON(w, r, envelope{"blog": b}, http.StatusCreated)
 
 }
+
+// canEditBlog reports whether the user making r owns blog or holds the permission to
+// edit any post. A request made with an API key also needs that permission among the
+// scopes of the key to edit the posts of others.
+func (app *application) canEditBlog(r *http.Request, blog *data.Blog) (bool, error) {
+	if app.contextGetUser(r).ID == blog.UserID {
+		return true, nil
+	}
+	permissions, err := app.userPermissions(r)
+	if err != nil {
+		return false, err
+	}
+	if key := app.contextGetAPIKey(r); key != nil && !data.Permissions(key.Scopes).Include(data.PermissionBlogsEdit) {
+		return false, nil
+	}
+	return permissions.Include(data.PermissionBlogsEdit), nil
+}
+
+// visibleBlogForRequest loads the blog named by the :id parameter. Hidden blogs are
+// only visible to their owner, everyone else gets a 404 as if they did not exist. It
+// writes the error response itself and returns false on failure.
+func (app *application) visibleBlogForRequest(w http.ResponseWriter, r *http.Request) (*data.Blog, bool) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return nil, false
+	}
+	blog, err := app.models.BlogModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return nil, false
+	}
+	if blog.Hidden && blog.UserID != app.contextGetUser(r).ID {
+		app.notFoundErrorResponse(w, r)
+		return nil, false
+	}
+	return blog, true
+}
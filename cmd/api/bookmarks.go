@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+func (app *application) createBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	blog, ok := app.visibleBlogForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user := app.contextGetUser(r)
+	err := app.models.BookmarkModel.Insert(user.ID, blog.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"bookmark": envelope{"blog_id": blog.ID, "bookmarked": true}}, http.StatusCreated)
+}
+
+func (app *application) deleteBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return
+	}
+
+	user := app.contextGetUser(r)
+	result, err := app.models.BookmarkModel.Delete(user.ID, id)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if result == 0 {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+func (app *application) listBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	filters := data.Filters{
+		Page:     app.readIntQuery(qs, "page", 1, v),
+		PageSize: app.readIntQuery(qs, "page_size", 20, v),
+	}
+	if data.ValidateFilters(v, filters); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	blogs, metadata, err := app.models.BookmarkModel.GetAllForUser(user.ID, filters)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"bookmarks": blogs, "metadata": metadata}, http.StatusOK)
+}
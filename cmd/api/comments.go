@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+func (app *application) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	blog, ok := app.visibleBlogForRequest(w, r)
+	if !ok {
+		return
+	}
+	comments, err := app.models.CommentModel.GetAllForBlog(blog.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"comments": comments}, http.StatusOK)
+}
+
+// createCommentHandler adds a comment to a blog, notifying its author and the users
+// mentioned in the comment.
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	blog, ok := app.visibleBlogForRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Content string `json:"content"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	user := app.contextGetUser(r)
+	profile, err := app.models.ProfileModel.Get(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	comment := &data.Comment{
+		BlogID:     blog.ID,
+		UserID:     &user.ID,
+		AuthorName: commentAuthorName(profile),
+		Content:    strings.TrimSpace(input.Content),
+		CreatedAt:  time.Now(),
+	}
+	v := validator.New()
+	if data.ValidateComment(v, comment); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	err = app.models.CommentModel.Insert(comment)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.notify(blog.UserID, user.ID, data.NotificationComment, blog.ID, fmt.Sprintf("%s commented on your post %q", comment.AuthorName, blog.Title))
+	app.notifyMentions(user.ID, blog.ID, comment.Content, fmt.Sprintf("%s mentioned you in a comment on %q", comment.AuthorName, blog.Title), blog.UserID)
+	app.writeJSON(w, r, envelope{"comment": comment}, http.StatusCreated)
+}
+
+// commentAuthorName is the name comments and notifications show for the user of
+// profile, which never reveals their email.
+func commentAuthorName(profile *data.Profile) string {
+	switch {
+	case profile.DisplayName != "":
+		return profile.DisplayName
+	case profile.Username != "":
+		return profile.Username
+	default:
+		return fmt.Sprintf("user %d", profile.UserID)
+	}
+}
+
+// notifyMentions sends message to the users @mentioned in text by actorID, except
+// skipUserID, who is notified about the text in another way.
+func (app *application) notifyMentions(actorID, blogID int, text, message string, skipUserID int) {
+	usernames := data.ExtractMentions(text)
+	if len(usernames) == 0 {
+		return
+	}
+	app.background(func() {
+		ids, err := app.models.ProfileModel.GetUserIDs(usernames)
+		if err != nil {
+			app.errorlog.Println(err)
+			return
+		}
+		for _, id := range ids {
+			if id != skipUserID {
+				app.notify(id, actorID, data.NotificationMention, blogID, message)
+			}
+		}
+	})
+}
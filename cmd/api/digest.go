@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+const (
+	digestCheckInterval  = time.Hour
+	digestBatchSize      = 100
+	digestPostLimit      = 10
+	digestUnsubscribeTTL = 30 * 24 * time.Hour
+)
+
+// digestPeriodStart returns midnight UTC of the Monday starting the week that contains t.
+func digestPeriodStart(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// runDigests periodically sends the digest for the previous week to every user who
+// has not received it yet. Each send is claimed in digest_sends before the email goes
+// out, so restarting the process part way through never sends a digest twice.
+func (app *application) runDigests() {
+	for {
+		err := app.sendDueDigests(time.Now())
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+		time.Sleep(digestCheckInterval)
+	}
+}
+
+func (app *application) sendDueDigests(now time.Time) error {
+	periodStart := digestPeriodStart(now)
+	from := periodStart.AddDate(0, 0, -7)
+
+	popular, err := app.models.DigestModel.GetPopularPosts(from, periodStart, digestPostLimit)
+	if err != nil {
+		return err
+	}
+
+	// Digests that fail during this run are only retried on the next one. The margin
+	// covers sent_at being rounded to the second.
+	retryBefore := now.Add(-time.Minute)
+	for {
+		users, err := app.models.DigestModel.GetRecipientsDue(periodStart, retryBefore, digestBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return nil
+		}
+		for _, user := range users {
+			// A digest that cannot be sent is marked failed and the loop moves on. Stop
+			// only when that fails too, so an unclaimable user cannot make this loop spin;
+			// the remaining users are picked up on the next run.
+			err := app.sendDigest(user, from, periodStart, popular)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (app *application) sendDigest(user data.User, from, periodStart time.Time, popular []data.Blog) error {
+	claimed, err := app.models.DigestModel.Claim(user.ID, periodStart)
+	if err != nil || !claimed {
+		return err
+	}
+	status, err := app.mailDigest(user, from, periodStart, popular)
+	if err != nil {
+		app.errorlog.Println(err)
+		status = data.DigestFailed
+	}
+	return app.models.DigestModel.Finish(user.ID, periodStart, status)
+}
+
+// mailDigest sends the digest of the claimed user and returns the status to
+// finish its claim with.
+func (app *application) mailDigest(user data.User, from, periodStart time.Time, popular []data.Blog) (string, error) {
+	followed, err := app.models.DigestModel.GetFollowedPosts(user.ID, from, periodStart, digestPostLimit)
+	if err != nil {
+		return "", err
+	}
+	if len(followed) == 0 && len(popular) == 0 {
+		return data.DigestSkipped, nil
+	}
+
+	token, err := app.models.TokenModel.New(user.ID, digestUnsubscribeTTL, data.ScopeUnsubscribe)
+	if err != nil {
+		return "", err
+	}
+	unsubscribeURL := app.config.baseURL + "/api/v1/digest/unsubscribe?token=" + url.QueryEscape(token.Plaintext)
+
+	// List-Unsubscribe-Post lets mail clients unsubscribe with a single POST to the
+	// unsubscribe URL, as described in RFC 8058.
+	headers := map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	err = app.mailer.SendTemplateWithHeaders(user.Email, "digest.tmpl", map[string]interface{}{
+		"PeriodStart":    from,
+		"FollowedPosts":  followed,
+		"PopularPosts":   popular,
+		"BaseURL":        app.config.baseURL,
+		"UnsubscribeURL": unsubscribeURL,
+	}, headers)
+	if err != nil {
+		return "", err
+	}
+	return data.DigestSent, nil
+}
+
+// digestUnsubscribePage asks the reader to confirm, since mail scanners follow the
+// links in emails and would otherwise unsubscribe them.
+var digestUnsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!doctype html>
+<html>
+<head>
+    <meta name="viewport" content="width=device-width" />
+    <title>Unsubscribe from the Goblog digest</title>
+</head>
+<body>
+    <p>Do you want to stop receiving the weekly Goblog digest?</p>
+    <form method="post" action="/api/v1/digest/unsubscribe?token={{.}}">
+        <button type="submit">Unsubscribe</button>
+    </form>
+</body>
+</html>
+`))
+
+// showDigestUnsubscribeHandler serves the page the unsubscribe link in the email
+// opens. It changes nothing, only the POST the page makes does.
+func (app *application) showDigestUnsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, token); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err := digestUnsubscribePage.Execute(w, token)
+	if err != nil {
+		app.errorlog.Println(err)
+	}
+}
+
+// unsubscribeDigestHandler disables the weekly digest for the owner of the token. It
+// is the target of the confirmation page and of RFC 8058 one-click unsubscribe.
+func (app *application) unsubscribeDigestHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, token); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user, err := app.models.UserModel.GetForToken(data.ScopeUnsubscribe, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			v.AddErrorMessage("token", "invalid or expired unsubscribe token")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+
+	err = app.models.DigestModel.SetEnabled(user.ID, false)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"message": "you have been unsubscribed from the weekly digest"}, http.StatusOK)
+}
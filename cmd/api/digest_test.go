@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestUnsubscribeLinkOnlyConfirms(t *testing.T) {
+	app := &application{errorlog: log.New(io.Discard, "", 0)}
+	token := strings.Repeat("A", 26)
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/digest/unsubscribe?token="+token, nil)
+	app.showDigestUnsubscribeHandler(rr, r)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, `method="post"`) || !strings.Contains(body, "token="+token) {
+		t.Fatalf("confirmation page does not post the token back:\n%s", body)
+	}
+}
+
+func TestDigestPeriodStart(t *testing.T) {
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 5, 15, 13, 45, 0, 0, time.UTC), time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 5, 19, 23, 59, 59, 0, time.UTC), time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 5, 20, 1, 0, 0, 0, time.FixedZone("UTC+2", 2*3600)), time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := digestPeriodStart(tt.now); !got.Equal(tt.want) {
+			t.Errorf("digestPeriodStart(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
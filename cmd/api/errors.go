@@ -3,6 +3,8 @@ package main
 import (
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"time"
 )
 
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, message interface{}, status int) {
@@ -46,6 +48,26 @@ func (app *application) inactiveAccountErrorResponse(w http.ResponseWriter, r *h
 	app.errorResponse(w, r, message, http.StatusForbidden)
 }
 
+func (app *application) suspendedAccountErrorResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account has been suspended"
+	app.errorResponse(w, r, message, http.StatusForbidden)
+}
+
+func (app *application) twoFactorRequiredErrorResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a two-factor authentication code is required in totp_code"
+	app.errorResponse(w, r, message, http.StatusUnauthorized)
+}
+
+func (app *application) notPermittedErrorResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, r, message, http.StatusForbidden)
+}
+
+func (app *application) apiKeyNotPermittedErrorResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this API key doesn't have the necessary scope to access this resource"
+	app.errorResponse(w, r, message, http.StatusForbidden)
+}
+
 func (app *application) notFoundErrorResponse(w http.ResponseWriter, r *http.Request) {
 	message := "not found"
 	app.errorResponse(w, r, message, http.StatusNotFound)
@@ -56,6 +78,12 @@ func (app *application) unauthorizedErrorResponse(w http.ResponseWriter, r *http
 	app.errorResponse(w, r, message, http.StatusUnauthorized)
 }
 
+func (app *application) loginLockedErrorResponse(w http.ResponseWriter, r *http.Request, until time.Time) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+	message := "too many failed login attempts, try again later"
+	app.errorResponse(w, r, message, http.StatusTooManyRequests)
+}
+
 func (app *application) rateLimitErrorResponse(w http.ResponseWriter, r *http.Request) {
 	message := "you have been rate limited"
 	app.errorResponse(w, r, message, http.StatusTooManyRequests)
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return
+	}
+	user := app.contextGetUser(r)
+	if id == user.ID {
+		app.badRequestErrorResponse(w, r, "you cannot follow yourself")
+		return
+	}
+	_, err = app.models.UserModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+
+	following, err := app.models.FollowModel.Exists(user.ID, id)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !following {
+		profile, err := app.models.ProfileModel.Get(user.ID)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+		err = app.models.FollowModel.Insert(user.ID, id)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+		app.notify(id, user.ID, data.NotificationFollow, 0, fmt.Sprintf("%s started following you", commentAuthorName(profile)))
+	}
+	counts, err := app.models.FollowModel.Counts(id)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"follows": counts, "following": true}, http.StatusCreated)
+}
+
+func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return
+	}
+	user := app.contextGetUser(r)
+	result, err := app.models.FollowModel.Delete(user.ID, id)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if result == 0 {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+func (app *application) showFollowsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return
+	}
+	_, err = app.models.UserModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	counts, err := app.models.FollowModel.Counts(id)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+
+	env := envelope{"follows": counts}
+	user := app.contextGetUser(r)
+	if !user.IsAnonymous() {
+		following, err := app.models.FollowModel.Exists(user.ID, id)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+		env["following"] = following
+	}
+	app.writeJSON(w, r, env, http.StatusOK)
+}
+
+func (app *application) feedHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	limit := app.readIntQuery(qs, "page_size", 20, v)
+	v.Check(limit > 0, "page_size", "must be greater than zero")
+	v.Check(limit <= 100, "page_size", "must be a maximum of 100")
+
+	var cursor *data.Cursor
+	if value := qs.Get("cursor"); value != "" {
+		c, err := data.DecodeCursor(value)
+		if err != nil {
+			v.AddErrorMessage("cursor", "is invalid")
+		}
+		cursor = c
+	}
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	blogs, next, err := app.models.FollowModel.Feed(user.ID, cursor, limit)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	var nextCursor string
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+	app.writeJSON(w, r, envelope{"blogs": blogs, "next_cursor": nextCursor}, http.StatusOK)
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/sulavmhrzn/goblog/internal/validator"
 )
 
 type envelope map[string]interface{}
@@ -25,6 +32,56 @@ func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, data e
 	return nil
 }
 
+// writeJSONConditional works like writeJSON for 200 responses but sets a strong ETag
+// computed from the body, and a Last-Modified header when lastModified is not zero.
+// It answers 304 Not Modified when the request's validators still match.
+func (app *application) writeJSONConditional(w http.ResponseWriter, r *http.Request, data envelope, lastModified time.Time) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(js)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+	return nil
+}
+
+// notModified evaluates If-None-Match, and only when it is absent If-Modified-Since,
+// as described in RFC 9110 section 13.2.2.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		if err == nil && !lastModified.Truncate(time.Second).After(since) {
+			return true
+		}
+	}
+	return false
+}
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
@@ -59,6 +116,39 @@ func (app *application) readInt(r *http.Request) (int, error) {
 	return id, nil
 }
 
+// clientIP returns the ip address of the client, without the port.
+func (app *application) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// clientUserAgent returns the User-Agent header, truncated so a client can't store
+// arbitrarily large values.
+func (app *application) clientUserAgent(r *http.Request) string {
+	userAgent := r.UserAgent()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	return userAgent
+}
+
+// readIntQuery reads an integer query string value, falling back to defaultValue when it is absent.
+func (app *application) readIntQuery(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	value := qs.Get(key)
+	if value == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		v.AddErrorMessage(key, "must be an integer value")
+		return defaultValue
+	}
+	return i
+}
+
 func (app *application) background(fn func()) {
 	go func() {
 		defer func() {
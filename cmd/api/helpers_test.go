@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	const etag = `"0123456789abcdef"`
+	lastModified := time.Date(2024, 5, 1, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	at := func(d time.Duration) string { return lastModified.Add(d).Format(http.TimeFormat) }
+
+	tests := []struct {
+		name         string
+		method       string
+		ifNoneMatch  string
+		ifModSince   string
+		lastModified time.Time
+		want         bool
+	}{
+		{"no validators", http.MethodGet, "", "", lastModified, false},
+		{"matching etag", http.MethodGet, etag, "", lastModified, true},
+		{"weak etag compares equal", http.MethodGet, `W/` + etag, "", lastModified, true},
+		{"etag among several", http.MethodGet, `"other", ` + etag, "", lastModified, true},
+		{"wildcard", http.MethodGet, "*", "", lastModified, true},
+		{"different etag", http.MethodGet, `"other"`, "", lastModified, false},
+		{"head request", http.MethodHead, etag, "", lastModified, true},
+		{"post request", http.MethodPost, etag, "", lastModified, false},
+		{"If-None-Match wins over a matching date", http.MethodGet, `"other"`, at(time.Hour), lastModified, false},
+		{"If-None-Match wins over a stale date", http.MethodGet, etag, at(-time.Hour), lastModified, true},
+		{"unmodified since", http.MethodGet, "", at(0), lastModified, true},
+		{"unmodified since a later date", http.MethodGet, "", at(time.Hour), lastModified, true},
+		{"modified since", http.MethodGet, "", at(-time.Second), lastModified, false},
+		{"zero Last-Modified", http.MethodGet, "", at(time.Hour), time.Time{}, false},
+		{"unparsable date", http.MethodGet, "", "yesterday", lastModified, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.ifModSince != "" {
+				r.Header.Set("If-Modified-Since", tt.ifModSince)
+			}
+			if got := notModified(r, etag, tt.lastModified); got != tt.want {
+				t.Errorf("notModified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
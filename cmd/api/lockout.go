@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+// loginFailureWindow is how long failed logins are remembered after the last one.
+const loginFailureWindow = 24 * time.Hour
+
+// loginPolicy allows threshold failed logins, then locks the subject out for base,
+// doubling with every further failure up to max.
+type loginPolicy struct {
+	kind      string
+	threshold int
+	base      time.Duration
+	max       time.Duration
+}
+
+var (
+	emailLoginPolicy = loginPolicy{kind: data.LoginFailureEmail, threshold: 5, base: 30 * time.Second, max: time.Hour}
+	ipLoginPolicy    = loginPolicy{kind: data.LoginFailureIP, threshold: 20, base: 30 * time.Second, max: time.Hour}
+)
+
+func (p loginPolicy) lockout(failures int) time.Duration {
+	if failures < p.threshold {
+		return 0
+	}
+	lockout := p.base
+	for i := p.threshold; i < failures && lockout < p.max; i++ {
+		lockout *= 2
+	}
+	if lockout > p.max {
+		lockout = p.max
+	}
+	return lockout
+}
+
+// alertsOwner reports whether failures is the failed login that first locks out the
+// email, so the owner of the account is emailed once rather than on every attempt.
+func (p loginPolicy) alertsOwner(failures int) bool {
+	return p.kind == data.LoginFailureEmail && failures == p.threshold
+}
+
+// loginSubject normalises email so differently cased spellings share one counter.
+func loginSubject(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginLockedUntil returns when the lockout of email or the client IP of r ends, or
+// the zero time if neither is locked out.
+func (app *application) loginLockedUntil(r *http.Request, email string) (time.Time, error) {
+	var until time.Time
+	subjects := map[string]string{
+		data.LoginFailureEmail: loginSubject(email),
+		data.LoginFailureIP:    app.clientIP(r),
+	}
+	for kind, subject := range subjects {
+		failure, err := app.models.LoginFailureModel.Get(kind, subject)
+		if err != nil {
+			if errors.Is(err, data.ErrNoRows) {
+				continue
+			}
+			return time.Time{}, err
+		}
+		if failure.LockedUntil != nil && failure.LockedUntil.After(time.Now()) && failure.LockedUntil.After(until) {
+			until = *failure.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+// recordLoginFailure counts a failed login for email and the client IP of r, locking
+// them out once their policy says so. The owner of the account, if there is one, is
+// emailed the first time it gets locked out.
+func (app *application) recordLoginFailure(r *http.Request, email string, user *data.User) error {
+	ip := app.clientIP(r)
+	for _, attempt := range []struct {
+		policy  loginPolicy
+		subject string
+	}{
+		{emailLoginPolicy, loginSubject(email)},
+		{ipLoginPolicy, ip},
+	} {
+		failures, err := app.models.LoginFailureModel.Record(attempt.policy.kind, attempt.subject, loginFailureWindow)
+		if err != nil {
+			return err
+		}
+		lockout := attempt.policy.lockout(failures)
+		if lockout == 0 {
+			continue
+		}
+		until := time.Now().Add(lockout)
+		err = app.models.LoginFailureModel.Lock(attempt.policy.kind, attempt.subject, until)
+		if err != nil {
+			return err
+		}
+		if attempt.policy.alertsOwner(failures) && user != nil {
+			app.background(func() {
+				body := fmt.Sprintf("There were %d failed attempts to sign in to your Goblog account, the last one from %s. "+
+					"Signing in is blocked until %s.\n\nIf this wasn't you, someone may be guessing your password. "+
+					"Consider changing it and enabling two-factor authentication.",
+					failures, ip, until.UTC().Format(time.RFC1123))
+				err := app.mailer.Send(user.Email, "Suspicious sign in attempts on your Goblog account", body)
+				if err != nil {
+					app.errorlog.Println(err)
+				}
+			})
+		}
+	}
+	return nil
+}
+
+// failedLoginResponse records the failed login and responds with invalid credentials.
+func (app *application) failedLoginResponse(w http.ResponseWriter, r *http.Request, email string, user *data.User) {
+	err := app.recordLoginFailure(r, email, user)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.invalidCredentialsErrorResponse(w, r)
+}
+
+// checkPassword confirms password before a sensitive change to the account of user.
+// Wrong guesses count as failed logins and the check is refused while the account is
+// locked out, so a stolen session cannot be used to guess the password. It writes the
+// response and returns false unless the password matches; field names the input.
+func (app *application) checkPassword(w http.ResponseWriter, r *http.Request, user *data.User, password, field string) bool {
+	lockedUntil, err := app.loginLockedUntil(r, user.Email)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return false
+	}
+	if !lockedUntil.IsZero() {
+		app.loginLockedErrorResponse(w, r, lockedUntil)
+		return false
+	}
+	match, err := user.Password.Matches(password)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return false
+	}
+	if !match {
+		err = app.recordLoginFailure(r, user.Email, user)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return false
+		}
+		v := validator.New()
+		v.AddErrorMessage(field, "is incorrect")
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return false
+	}
+	return true
+}
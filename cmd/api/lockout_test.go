@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+)
+
+func TestLoginPolicyLockout(t *testing.T) {
+	p := loginPolicy{kind: data.LoginFailureEmail, threshold: 5, base: 30 * time.Second, max: 5 * time.Minute}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{4, 0},
+		{5, 30 * time.Second},
+		{6, time.Minute},
+		{7, 2 * time.Minute},
+		{8, 4 * time.Minute},
+		{9, 5 * time.Minute}, // capped at max
+		{10, 5 * time.Minute},
+		{1000, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.lockout(tt.failures); got != tt.want {
+			t.Errorf("lockout(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginPolicyAlertsOwnerOnce(t *testing.T) {
+	for failures := 0; failures <= 2*emailLoginPolicy.threshold; failures++ {
+		want := failures == emailLoginPolicy.threshold
+		if got := emailLoginPolicy.alertsOwner(failures); got != want {
+			t.Errorf("email policy: alertsOwner(%d) = %v, want %v", failures, got, want)
+		}
+	}
+	// An IP address has no owner to alert, even when it is locked out.
+	if ipLoginPolicy.alertsOwner(ipLoginPolicy.threshold) {
+		t.Errorf("ip policy: alertsOwner(%d) = true, want false", ipLoginPolicy.threshold)
+	}
+}
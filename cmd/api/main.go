@@ -9,24 +9,59 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/sulavmhrzn/goblog/internal/broadcast"
 	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/jwt"
 	"github.com/sulavmhrzn/goblog/internal/mailer"
+	"github.com/sulavmhrzn/goblog/internal/oidc"
+	"github.com/sulavmhrzn/goblog/internal/webhook"
 )
 
 type config struct {
-	port int
-	dsn  string
-	smtp struct {
+	port    int
+	dsn     string
+	baseURL string
+	smtp    struct {
 		host     string
 		port     int
 		username string
 		password string
 		sender   string
 	}
+	digest struct {
+		enabled bool
+	}
+	newsletter struct {
+		batchSize     int
+		batchInterval time.Duration
+	}
+	stream struct {
+		maxClients   int
+		maxPerClient int
+		maxDuration  time.Duration
+	}
+	admin struct {
+		emails []string
+	}
+	tokens struct {
+		accessTTL  time.Duration
+		refreshTTL time.Duration
+	}
+	oidc struct {
+		configFile string
+	}
+	jwt struct {
+		keys      string
+		activeKey string
+	}
+	accounts struct {
+		deletionGracePeriod time.Duration
+	}
 }
 type application struct {
 	infolog  *log.Logger
@@ -34,6 +69,14 @@ type application struct {
 	config   config
 	models   data.Models
 	mailer   mailer.Mailer
+	webhooks webhook.Client
+	broker   *broadcast.Broker
+	streams  *streamLimiter
+	oidc     map[string]*oidc.Provider
+
+	// jwt is nil unless access tokens are stateless JWTs.
+	jwt         *jwt.Keys
+	revocations *revocationList
 }
 
 func main() {
@@ -50,8 +93,28 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", os.Getenv("SMTP_PASSWORD"), "SMTP password")
 	flag.StringVar(&cfg.smtp.username, "smtp-username", os.Getenv("SMTP_USERNAME"), "SMTP username")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", os.Getenv("SMTP_SENDER"), "SMTP sender")
+	flag.StringVar(&cfg.baseURL, "base-url", "https://localhost:4000", "Public base URL used in links sent by email")
+	flag.BoolVar(&cfg.digest.enabled, "digest-enabled", false, "Send the weekly email digest")
+	flag.IntVar(&cfg.newsletter.batchSize, "newsletter-batch-size", 50, "Number of newsletter emails sent per batch")
+	flag.DurationVar(&cfg.newsletter.batchInterval, "newsletter-batch-interval", 10*time.Second, "Pause between newsletter batches")
+	flag.IntVar(&cfg.stream.maxClients, "stream-max-clients", 1000, "Maximum concurrent event stream connections")
+	flag.IntVar(&cfg.stream.maxPerClient, "stream-max-per-client", 5, "Maximum concurrent event stream connections per client ip")
+	flag.DurationVar(&cfg.stream.maxDuration, "stream-max-duration", time.Hour, "Maximum lifetime of an event stream connection")
+	flag.DurationVar(&cfg.tokens.accessTTL, "access-token-ttl", 15*time.Minute, "Lifetime of authentication tokens")
+	flag.DurationVar(&cfg.tokens.refreshTTL, "refresh-token-ttl", 30*24*time.Hour, "Lifetime of refresh tokens")
+	flag.StringVar(&cfg.oidc.configFile, "oidc-config", os.Getenv("OIDC_CONFIG"), "Path to a JSON file of OpenID Connect providers users can sign in with")
+	flag.StringVar(&cfg.jwt.keys, "jwt-keys", os.Getenv("JWT_KEYS"), "Comma separated kid:secret pairs for signing stateless access tokens, which replace database tokens when set")
+	flag.StringVar(&cfg.jwt.activeKey, "jwt-active-key", os.Getenv("JWT_ACTIVE_KEY"), "Kid of the key new access tokens are signed with")
+	flag.DurationVar(&cfg.accounts.deletionGracePeriod, "account-deletion-grace-period", 30*24*time.Hour, "Time before a deleted account is purged, during which the deletion can be cancelled")
+	adminEmails := flag.String("admin-emails", os.Getenv("ADMIN_EMAILS"), "Comma separated emails of users granted the admin role on startup")
 	flag.Parse()
 
+	for _, email := range strings.Split(*adminEmails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			cfg.admin.emails = append(cfg.admin.emails, email)
+		}
+	}
+
 	if cfg.smtp.port == 0 {
 		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
 		if err != nil {
@@ -72,10 +135,53 @@ func main() {
 		config:   cfg,
 		models:   data.NewModels(db),
 		mailer:   mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		webhooks: webhook.New(nil),
+		broker:   broadcast.New(100, 16),
+		streams:  newStreamLimiter(cfg.stream.maxClients, cfg.stream.maxPerClient),
+		oidc:     make(map[string]*oidc.Provider),
+
+		revocations: newRevocationList(),
+	}
+
+	if cfg.jwt.keys != "" {
+		app.jwt, err = jwt.ParseKeys(cfg.jwt.keys, cfg.jwt.activeKey)
+		if err != nil {
+			logger.Fatal(err.Error())
+		}
+		err = app.loadRevocations()
+		if err != nil {
+			logger.Fatal(err.Error())
+		}
+		app.background(app.runRevocationSync)
+	}
+
+	if cfg.oidc.configFile != "" {
+		providers, err := oidc.LoadConfig(cfg.oidc.configFile)
+		if err != nil {
+			logger.Fatal(err.Error())
+		}
+		for _, provider := range providers {
+			provider.RedirectURL = strings.TrimRight(cfg.baseURL, "/") + "/api/v1/oidc/" + provider.Name + "/callback"
+			app.oidc[provider.Name] = oidc.NewProvider(provider, nil)
+		}
+	}
+
+	for _, email := range cfg.admin.emails {
+		err = app.models.RoleModel.AddForEmail(email, data.RoleAdmin)
+		if err != nil {
+			logger.Fatal(err.Error())
+		}
+	}
+
+	app.background(app.runWebhookDeliveries)
+	app.background(app.runAccountPurges)
+	if cfg.digest.enabled {
+		app.background(app.runDigests)
 	}
 
 	app.infolog.Println("Database connection successfull")
 	app.infolog.Println("server running on port: ", cfg.port)
+	// streamHandler extends its own write deadline, so WriteTimeout does not end event streams.
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.port),
 		Handler:      app.router(),
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/jwt"
 	"github.com/sulavmhrzn/goblog/internal/validator"
 	"golang.org/x/time/rate"
 )
@@ -31,6 +32,15 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 		token := headerParts[1]
 
+		if data.IsAPIKey(token) {
+			app.authenticateAPIKey(w, r, token, next)
+			return
+		}
+		if app.jwt != nil && jwt.IsJWT(token) {
+			app.authenticateJWT(w, r, token, next)
+			return
+		}
+
 		v := validator.New()
 		if data.ValidateTokenPlaintext(v, token); !v.IsValid() {
 			app.invalidAuthenticationTokenErrorResponse(w, r)
@@ -47,25 +57,103 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			}
 			return
 		}
+		err = app.models.TokenModel.Touch(token)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
 		r = app.contextSetUser(r, user)
+		r = app.contextSetToken(r, token)
 
 		next.ServeHTTP(w, r)
 
 	})
 }
+
+func (app *application) authenticateAPIKey(w http.ResponseWriter, r *http.Request, plaintext string, next http.Handler) {
+	v := validator.New()
+	if data.ValidateAPIKeyPlaintext(v, plaintext); !v.IsValid() {
+		app.invalidAuthenticationTokenErrorResponse(w, r)
+		return
+	}
+	key, err := app.models.APIKeyModel.GetForPlaintext(plaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.invalidCredentialsErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	user, err := app.models.UserModel.Get(key.UserID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	r = app.contextSetUser(r, user)
+	r = app.contextSetAPIKey(r, key)
+
+	next.ServeHTTP(w, r)
+}
+
+// authenticateJWT accepts a stateless access token without querying the database. The
+// user is built from its claims, so it only has an ID and activation state.
+func (app *application) authenticateJWT(w http.ResponseWriter, r *http.Request, token string, next http.Handler) {
+	claims, err := app.jwt.Verify(token, time.Now())
+	if err != nil {
+		app.invalidAuthenticationTokenErrorResponse(w, r)
+		return
+	}
+	if app.revocations.revoked(claims) {
+		app.invalidAuthenticationTokenErrorResponse(w, r)
+		return
+	}
+	user := &data.User{ID: claims.Subject, Activated: claims.Activated}
+	r = app.contextSetUser(r, user)
+	r = app.contextSetToken(r, token)
+	r = app.contextSetClaims(r, claims)
+
+	next.ServeHTTP(w, r)
+}
+
+// requireAuthenticatedUser rejects requests made with an API key, which are limited
+// to the routes requirePermission guards with one of their scopes.
 func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetAPIKey(r) != nil {
+			app.apiKeyNotPermittedErrorResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+	return app.requireAuthentication(fn)
+}
+
+// requireAuthentication accepts both session tokens and API keys, unless the account
+// is suspended. Stateless access tokens do not carry the suspension, but suspending an
+// account revokes them.
+func (app *application) requireAuthentication(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := app.contextGetUser(r)
 		if user.IsAnonymous() {
 			app.authenticationRequiredErrorResponse(w, r)
 			return
 		}
+		if user.Suspended {
+			app.suspendedAccountErrorResponse(w, r)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
 func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
-	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return app.requireAuthenticatedUser(app.requireActiveAccount(next))
+}
+
+func (app *application) requireActiveAccount(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := app.contextGetUser(r)
 		if !user.Activated {
 			app.inactiveAccountErrorResponse(w, r)
@@ -73,7 +161,38 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 		}
 		next.ServeHTTP(w, r)
 	})
-	return app.requireAuthenticatedUser(fn)
+}
+
+// requirePermission lets through activated users holding code. Requests made with an
+// API key also need code among the scopes of the key. Stateless access tokens carry
+// the permissions of the user, which saves the query.
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		permissions, err := app.userPermissions(r)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+		if !permissions.Include(code) {
+			app.notPermittedErrorResponse(w, r)
+			return
+		}
+		if key := app.contextGetAPIKey(r); key != nil && !data.Permissions(key.Scopes).Include(code) {
+			app.apiKeyNotPermittedErrorResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+	return app.requireAuthentication(app.requireActiveAccount(fn))
+}
+
+// userPermissions returns the permissions of the user making the request, ignoring the
+// scopes of an API key.
+func (app *application) userPermissions(r *http.Request) (data.Permissions, error) {
+	if claims := app.contextGetClaims(r); claims != nil {
+		return claims.Scopes, nil
+	}
+	return app.models.PermissionModel.GetAllForUser(app.contextGetUser(r).ID)
 }
 
 func (app *application) perClientRateLimiter(next http.Handler) http.Handler {
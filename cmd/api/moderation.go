@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+func (app *application) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return
+	}
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	blog, err := app.models.BlogModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	if blog.Hidden {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	report := &data.Report{
+		BlogID:     blog.ID,
+		ReporterID: user.ID,
+		Reason:     input.Reason,
+	}
+	v := validator.New()
+	v.Check(blog.UserID != user.ID, "blog", "you cannot report your own post")
+	if data.ValidateReport(v, report); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	err = app.models.ReportModel.Insert(report)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateReport):
+			v.AddErrorMessage("blog", "you have already reported this post")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	app.writeJSON(w, r, envelope{"report": report}, http.StatusCreated)
+}
+
+func (app *application) listReportsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	filters := data.Filters{
+		Page:     app.readIntQuery(qs, "page", 1, v),
+		PageSize: app.readIntQuery(qs, "page_size", 20, v),
+	}
+	status := data.ReportOpen
+	if qs.Has("status") {
+		status = qs.Get("status")
+	}
+	v.Check(status == "" || validator.In(status, data.ReportStatuses...), "status", "must be one of open, dismissed or actioned")
+	if data.ValidateFilters(v, filters); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	reports, metadata, err := app.models.ReportModel.GetAll(status, filters)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"reports": reports, "metadata": metadata}, http.StatusOK)
+}
+
+// actOnReportHandler resolves an open report. Hiding the post or suspending its author
+// also closes every other open report on the post, and each reporter is notified.
+func (app *application) actOnReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return
+	}
+	var input struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	report, err := app.models.ReportModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+
+	moderator := app.contextGetUser(r)
+	action := &data.ModerationAction{
+		ReportID:     &report.ID,
+		ModeratorID:  moderator.ID,
+		Action:       input.Action,
+		BlogID:       report.BlogID,
+		TargetUserID: report.AuthorID,
+		Note:         input.Note,
+	}
+	v := validator.New()
+	v.Check(report.Status == data.ReportOpen, "report", "has already been resolved")
+	v.Check(input.Action != data.ActionSuspendAuthor || report.AuthorID != moderator.ID, "action", "you cannot suspend yourself")
+	if data.ValidateModerationAction(v, action); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	var outcome string
+	reporters := []int{report.ReporterID}
+	switch action.Action {
+	case data.ActionDismiss:
+		err = app.models.ReportModel.Resolve(report.ID, data.ReportDismissed, moderator.ID)
+		outcome = "no action was taken"
+	case data.ActionHidePost:
+		err = app.models.BlogModel.SetHidden(report.BlogID, true)
+		if err == nil {
+			reporters, err = app.models.ReportModel.ResolveOpenForBlog(report.BlogID, data.ReportActioned, moderator.ID)
+		}
+		outcome = "the post has been hidden"
+	case data.ActionSuspendAuthor:
+		err = app.models.UserModel.SetSuspended(report.AuthorID, true)
+		if err == nil {
+			err = app.signOut(report.AuthorID, 0)
+		}
+		if err == nil {
+			reporters, err = app.models.ReportModel.ResolveOpenForBlog(report.BlogID, data.ReportActioned, moderator.ID)
+		}
+		outcome = "the author has been suspended"
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			v.AddErrorMessage("report", "has already been resolved")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+
+	err = app.models.ModerationActionModel.Insert(action)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+
+	message := fmt.Sprintf("Your report on %q was reviewed: %s.", report.BlogTitle, outcome)
+	for _, reporterID := range reporters {
+		app.notify(reporterID, 0, data.NotificationReport, report.BlogID, message)
+	}
+	app.writeJSON(w, r, envelope{"action": action}, http.StatusCreated)
+}
+
+// unhidePostHandler makes a post hidden by moderation visible again.
+func (app *application) unhidePostHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return
+	}
+	var input struct {
+		Note string `json:"note"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	blog, err := app.models.BlogModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	action := &data.ModerationAction{
+		ModeratorID:  app.contextGetUser(r).ID,
+		Action:       data.ActionUnhidePost,
+		BlogID:       blog.ID,
+		TargetUserID: blog.UserID,
+		Note:         input.Note,
+	}
+	v := validator.New()
+	v.Check(blog.Hidden, "blog", "is not hidden")
+	v.Check(len(action.Note) <= 1000, "note", "must not be more than 1000 bytes long")
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	err = app.models.BlogModel.SetHidden(blog.ID, false)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.ModerationActionModel.Insert(action)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"action": action}, http.StatusCreated)
+}
+
+// unsuspendUserHandler lifts the suspension of a user, who can then sign in again.
+func (app *application) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return
+	}
+	var input struct {
+		Note string `json:"note"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	user, err := app.models.UserModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	action := &data.ModerationAction{
+		ModeratorID:  app.contextGetUser(r).ID,
+		Action:       data.ActionUnsuspendUser,
+		TargetUserID: user.ID,
+		Note:         input.Note,
+	}
+	v := validator.New()
+	v.Check(user.Suspended, "user", "is not suspended")
+	// The deleted user stays suspended, so nobody can sign in as it.
+	v.Check(user.Email != data.DeletedUserEmail, "user", "the placeholder for deleted accounts cannot be unsuspended")
+	v.Check(len(action.Note) <= 1000, "note", "must not be more than 1000 bytes long")
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	err = app.models.UserModel.SetSuspended(user.ID, false)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.ModerationActionModel.Insert(action)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"action": action}, http.StatusCreated)
+}
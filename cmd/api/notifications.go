@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+// notify is the single entry point for producing notifications. It never notifies
+// users about their own actions, and the recipient's preferences decide whether
+// the notification is stored. actorID and blogID may be 0 when not applicable.
+func (app *application) notify(userID, actorID int, kind string, blogID int, message string) {
+	if userID == actorID {
+		return
+	}
+	n := &data.Notification{
+		UserID:  userID,
+		Kind:    kind,
+		Message: message,
+	}
+	if actorID != 0 {
+		n.ActorID = &actorID
+	}
+	if blogID != 0 {
+		n.BlogID = &blogID
+	}
+	app.background(func() {
+		_, err := app.models.NotificationModel.Insert(n)
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+	})
+}
+
+func (app *application) listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	filters := data.Filters{
+		Page:     app.readIntQuery(qs, "page", 1, v),
+		PageSize: app.readIntQuery(qs, "page_size", 20, v),
+	}
+	unreadOnly := qs.Get("unread") == "true"
+	if data.ValidateFilters(v, filters); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	notifications, metadata, err := app.models.NotificationModel.GetAllForUser(user.ID, unreadOnly, filters)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	unread, err := app.models.NotificationModel.UnreadCount(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"notifications": notifications, "unread_count": unread, "metadata": metadata}, http.StatusOK)
+}
+
+func (app *application) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		IDs []int `json:"ids"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	user := app.contextGetUser(r)
+	updated, err := app.models.NotificationModel.MarkRead(user.ID, input.IDs)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	unread, err := app.models.NotificationModel.UnreadCount(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"marked_read": updated, "unread_count": unread}, http.StatusOK)
+}
+
+func (app *application) showNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	preferences, err := app.models.NotificationModel.GetPreferences(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"preferences": preferences}, http.StatusOK)
+}
+
+func (app *application) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	var input map[string]bool
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	for kind := range input {
+		v.Check(validator.In(kind, data.NotificationKinds...), kind, "is not a known notification kind")
+	}
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	for kind, enabled := range input {
+		err = app.models.NotificationModel.SetPreference(user.ID, kind, enabled)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+	}
+	preferences, err := app.models.NotificationModel.GetPreferences(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"preferences": preferences}, http.StatusOK)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/oidc"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+func (app *application) oidcProviderForRequest(w http.ResponseWriter, r *http.Request) (*oidc.Provider, bool) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+	provider, ok := app.oidc[name]
+	if !ok {
+		app.notFoundErrorResponse(w, r)
+		return nil, false
+	}
+	return provider, true
+}
+
+// oidcLoginHandler redirects the user to the identity provider to sign in. The
+// provider sends them back to oidcCallbackHandler.
+func (app *application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oidcProviderForRequest(w, r)
+	if !ok {
+		return
+	}
+	state, err := oidc.RandomString()
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.OIDCStateModel.Insert(state, &data.OIDCState{
+		Provider:     provider.Name(),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+	}, oidcStateTTL)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, challenge)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallbackHandler finishes a sign in with an identity provider. The identity is
+// linked to the user with the same verified email, or to a new user, and a normal
+// Goblog session is issued. Once linked, the provider is trusted to have done any
+// second factor.
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oidcProviderForRequest(w, r)
+	if !ok {
+		return
+	}
+	qs := r.URL.Query()
+	if qs.Get("error") != "" {
+		app.badRequestErrorResponse(w, r, "sign in failed: "+qs.Get("error"))
+		return
+	}
+	state, err := app.models.OIDCStateModel.Take(qs.Get("state"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.badRequestErrorResponse(w, r, "invalid or expired state")
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	if state.Provider != provider.Name() {
+		app.badRequestErrorResponse(w, r, "invalid or expired state")
+		return
+	}
+
+	rawIDToken, err := provider.Exchange(r.Context(), qs.Get("code"), state.CodeVerifier)
+	if err != nil {
+		app.errorlog.Println(err)
+		app.invalidCredentialsErrorResponse(w, r)
+		return
+	}
+	claims, err := provider.VerifyIDToken(r.Context(), rawIDToken, state.Nonce)
+	if err != nil {
+		app.errorlog.Println(err)
+		app.invalidCredentialsErrorResponse(w, r)
+		return
+	}
+
+	user, needsCode, err := app.userForIdentity(provider.Name(), claims)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnverifiedEmail):
+			app.errorResponse(w, r, err.Error(), http.StatusForbidden)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	if user.Suspended {
+		app.suspendedAccountErrorResponse(w, r)
+		return
+	}
+	if needsCode {
+		token, err := oidc.RandomString()
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+		err = app.models.IdentityModel.InsertPending(token, &data.PendingIdentity{
+			Provider: provider.Name(),
+			Subject:  claims.Subject,
+			UserID:   user.ID,
+			Email:    claims.Email,
+		}, oidcStateTTL)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+		env := envelope{
+			"error":      "a two-factor authentication code is required in totp_code to link this sign in to your account",
+			"link_token": token,
+		}
+		app.writeJSON(w, r, env, http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := app.newSession(r, user, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"authentication_token": tokens.Access, "refresh_token": tokens.Refresh, "user": user}, http.StatusCreated)
+}
+
+// linkIdentityHandler links an identity to a user with two-factor authentication once
+// a code is given for the link token oidcCallbackHandler returned. The token is used
+// up by the attempt, so a wrong code means signing in with the provider again.
+func (app *application) linkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.oidcProviderForRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		LinkToken string `json:"link_token"`
+		TOTPCode  string `json:"totp_code"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.LinkToken != "", "link_token", "must be provided")
+	v.Check(input.TOTPCode != "", "totp_code", "must be provided")
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	pending, err := app.models.IdentityModel.TakePending(input.LinkToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.badRequestErrorResponse(w, r, "invalid or expired link token")
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	if pending.Provider != provider.Name() {
+		app.badRequestErrorResponse(w, r, "invalid or expired link token")
+		return
+	}
+	user, err := app.models.UserModel.Get(pending.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.badRequestErrorResponse(w, r, "invalid or expired link token")
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	if user.Suspended {
+		app.suspendedAccountErrorResponse(w, r)
+		return
+	}
+
+	enrollment, err := app.models.TOTPModel.Get(user.ID)
+	if err != nil && !errors.Is(err, data.ErrNoRows) {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if enrollment != nil && enrollment.Enabled {
+		ok, err := app.verifySecondFactor(enrollment, input.TOTPCode, true)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+		if !ok {
+			app.invalidCredentialsErrorResponse(w, r)
+			return
+		}
+	}
+
+	err = app.models.IdentityModel.Insert(pending.Provider, pending.Subject, user.ID, pending.Email)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	tokens, err := app.newSession(r, user, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"authentication_token": tokens.Access, "refresh_token": tokens.Refresh, "user": user}, http.StatusCreated)
+}
+
+var errUnverifiedEmail = errors.New("the identity provider did not return a verified email")
+
+// userForIdentity returns the user linked to the identity in claims, linking or
+// creating one by email on first sign in. An identity is not linked to a user with
+// two-factor authentication: needsCode is set instead and the caller has to ask for a
+// code first.
+//
+// A matching account that was never activated may have been registered by someone
+// else who knows its password, so the password is replaced with an unusable one and
+// its sessions are revoked before the account is activated and linked.
+func (app *application) userForIdentity(provider string, claims *oidc.Claims) (*data.User, bool, error) {
+	user, err := app.models.IdentityModel.GetUser(provider, claims.Subject)
+	if err == nil {
+		return user, false, nil
+	}
+	if !errors.Is(err, data.ErrNoRows) {
+		return nil, false, err
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, false, errUnverifiedEmail
+	}
+
+	user, err = app.models.UserModel.GetByEmail(claims.Email)
+	switch {
+	case errors.Is(err, data.ErrNoRows):
+		// The provider verified the email, so the new account starts activated.
+		user = &data.User{Email: claims.Email, Activated: true}
+		err = user.Password.SetUnusable()
+		if err != nil {
+			return nil, false, err
+		}
+		err = app.models.UserModel.Insert(user, data.RoleAuthor)
+		if err != nil {
+			return nil, false, err
+		}
+		app.triggerWebhookEvent(user.ID, data.EventUserActivated, user)
+	case err != nil:
+		return nil, false, err
+	case !user.Activated:
+		err = user.Password.SetUnusable()
+		if err != nil {
+			return nil, false, err
+		}
+		user.Activated = true
+		err = app.models.UserModel.Update(user)
+		if err != nil {
+			return nil, false, err
+		}
+		err = app.signOut(user.ID, 0)
+		if err != nil {
+			return nil, false, err
+		}
+		app.triggerWebhookEvent(user.ID, data.EventUserActivated, user)
+	default:
+		enrollment, err := app.models.TOTPModel.Get(user.ID)
+		if err != nil && !errors.Is(err, data.ErrNoRows) {
+			return nil, false, err
+		}
+		if enrollment != nil && enrollment.Enabled {
+			return user, true, nil
+		}
+	}
+
+	err = app.models.IdentityModel.Insert(provider, claims.Subject, user.ID, claims.Email)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, false, nil
+}
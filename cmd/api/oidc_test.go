@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/oidc"
+	"github.com/sulavmhrzn/goblog/internal/oidc/oidctest"
+	"github.com/sulavmhrzn/goblog/internal/totp"
+)
+
+// testDB connects to a new schema with every migration applied in the Postgres
+// database GOBLOG_TEST_DSN points to, as a postgres:// URL. The schema is dropped
+// when the test ends. Tests using it are skipped when the variable is unset.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("GOBLOG_TEST_DSN")
+	if dsn == "" {
+		t.Skip("GOBLOG_TEST_DSN is not set")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		t.Fatalf("GOBLOG_TEST_DSN must be a postgres:// URL")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	qs := u.Query()
+	qs.Set("search_path", schema)
+	u.RawQuery = qs.Encode()
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := filepath.Glob("../../migrations/*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, migration := range migrations {
+		query, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.Exec(string(query))
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(migration), err)
+		}
+	}
+	return db
+}
+
+func newOIDCTestApp(t *testing.T) (*application, *oidctest.Server) {
+	t.Helper()
+	db := testDB(t)
+	server, err := oidctest.NewServer("goblog", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "test",
+		Issuer:       server.URL,
+		ClientID:     server.ClientID,
+		ClientSecret: server.ClientSecret,
+		RedirectURL:  "https://goblog.example/api/v1/oidc/test/callback",
+	}, server.Client())
+
+	app := &application{
+		infolog:  log.New(io.Discard, "", 0),
+		errorlog: log.New(io.Discard, "", 0),
+		models:   data.NewModels(db),
+		oidc:     map[string]*oidc.Provider{"test": provider},
+	}
+	app.config.tokens.accessTTL = 15 * time.Minute
+	app.config.tokens.refreshTTL = time.Hour
+	return app, server
+}
+
+func withProvider(r *http.Request) *http.Request {
+	params := httprouter.Params{{Key: "provider", Value: "test"}}
+	return r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, params))
+}
+
+type oidcResponse struct {
+	User      data.User `json:"user"`
+	LinkToken string    `json:"link_token"`
+}
+
+// oidcSignIn signs in through oidcLoginHandler and the provider, and returns the
+// response of oidcCallbackHandler.
+func oidcSignIn(t *testing.T, app *application) (int, oidcResponse) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	app.oidcLoginHandler(rr, withProvider(httptest.NewRequest(http.MethodGet, "/api/v1/oidc/test/login", nil)))
+	if rr.Code != http.StatusFound {
+		t.Fatalf("login returned status %d, want %d", rr.Code, http.StatusFound)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	app.oidcCallbackHandler(rr, withProvider(httptest.NewRequest(http.MethodGet, "/api/v1/oidc/test/callback?"+callback.RawQuery, nil)))
+	return rr.Code, decodeOIDCResponse(t, rr)
+}
+
+func oidcLink(t *testing.T, app *application, linkToken, code string) (int, oidcResponse) {
+	t.Helper()
+	body := fmt.Sprintf(`{"link_token": %q, "totp_code": %q}`, linkToken, code)
+	rr := httptest.NewRecorder()
+	app.linkIdentityHandler(rr, withProvider(httptest.NewRequest(http.MethodPost, "/api/v1/oidc/test/link", strings.NewReader(body))))
+	return rr.Code, decodeOIDCResponse(t, rr)
+}
+
+func decodeOIDCResponse(t *testing.T, rr *httptest.ResponseRecorder) oidcResponse {
+	t.Helper()
+	var res oidcResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decoding %s: %v", rr.Body, err)
+	}
+	return res
+}
+
+func insertTestUser(t *testing.T, app *application, email string, activated bool) *data.User {
+	t.Helper()
+	user := &data.User{Email: email, Activated: activated}
+	if err := user.Password.Set("correct horse battery"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.UserModel.Insert(user, data.RoleAuthor); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestOIDCSignInCreatesActivatedUser(t *testing.T) {
+	app, server := newOIDCTestApp(t)
+	server.SetUser(oidctest.User{Subject: "new", Email: "new@example.com", EmailVerified: true})
+
+	status, res := oidcSignIn(t, app)
+	if status != http.StatusCreated {
+		t.Fatalf("got status %d, want %d", status, http.StatusCreated)
+	}
+	if res.User.ID == 0 || !res.User.Activated {
+		t.Fatalf("got user %+v, want a new activated user", res.User)
+	}
+
+	// Signing in again finds the user through the identity.
+	status, again := oidcSignIn(t, app)
+	if status != http.StatusCreated || again.User.ID != res.User.ID {
+		t.Errorf("second sign in: got status %d and user %d, want %d and user %d", status, again.User.ID, http.StatusCreated, res.User.ID)
+	}
+}
+
+func TestOIDCSignInRejectsUnverifiedEmail(t *testing.T) {
+	app, server := newOIDCTestApp(t)
+	insertTestUser(t, app, "owner@example.com", true)
+	server.SetUser(oidctest.User{Subject: "attacker", Email: "owner@example.com", EmailVerified: false})
+
+	if status, _ := oidcSignIn(t, app); status != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", status, http.StatusForbidden)
+	}
+}
+
+func TestOIDCSignInLinksExistingUserIgnoringCase(t *testing.T) {
+	app, server := newOIDCTestApp(t)
+	user := insertTestUser(t, app, "Writer@Example.com", true)
+	server.SetUser(oidctest.User{Subject: "writer", Email: "writer@example.com", EmailVerified: true})
+
+	status, res := oidcSignIn(t, app)
+	if status != http.StatusCreated || res.User.ID != user.ID {
+		t.Fatalf("got status %d and user %d, want %d and user %d", status, res.User.ID, http.StatusCreated, user.ID)
+	}
+	linked, err := app.models.IdentityModel.GetUser("test", "writer")
+	if err != nil || linked.ID != user.ID {
+		t.Errorf("identity is linked to %+v (%v), want user %d", linked, err, user.ID)
+	}
+}
+
+func TestOIDCSignInTakesOverUnactivatedUser(t *testing.T) {
+	app, server := newOIDCTestApp(t)
+	user := insertTestUser(t, app, "squatted@example.com", false)
+	session, err := app.models.TokenModel.NewSession(user.ID, 0, time.Hour, time.Hour, "192.0.2.1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetUser(oidctest.User{Subject: "owner", Email: "squatted@example.com", EmailVerified: true})
+
+	status, res := oidcSignIn(t, app)
+	if status != http.StatusCreated || res.User.ID != user.ID || !res.User.Activated {
+		t.Fatalf("got status %d and user %+v, want %d and user %d activated", status, res.User, http.StatusCreated, user.ID)
+	}
+
+	// Whoever registered the account can no longer use its password or sessions.
+	stored, err := app.models.UserModel.Get(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match, err := stored.Password.Matches("correct horse battery"); err != nil || match {
+		t.Errorf("the old password still matches (%v)", err)
+	}
+	_, err = app.models.UserModel.GetForToken(data.ScopeAuthentication, session.Access.Plaintext)
+	if !errors.Is(err, data.ErrNoRows) {
+		t.Errorf("old session: got %v, want %v", err, data.ErrNoRows)
+	}
+}
+
+func TestOIDCSignInAsksTwoFactorUserForCode(t *testing.T) {
+	app, server := newOIDCTestApp(t)
+	user := insertTestUser(t, app, "careful@example.com", true)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.models.TOTPModel.Enroll(user.ID, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.TOTPModel.Enable(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	server.SetUser(oidctest.User{Subject: "careful", Email: "careful@example.com", EmailVerified: true})
+
+	status, res := oidcSignIn(t, app)
+	if status != http.StatusUnauthorized || res.LinkToken == "" {
+		t.Fatalf("got status %d and link token %q, want %d and a link token", status, res.LinkToken, http.StatusUnauthorized)
+	}
+	if _, err := app.models.IdentityModel.GetUser("test", "careful"); !errors.Is(err, data.ErrNoRows) {
+		t.Fatalf("identity was linked before the code was given (%v)", err)
+	}
+
+	// A wrong code uses up the link token.
+	if status, _ := oidcLink(t, app, res.LinkToken, "000000"); status != http.StatusUnauthorized {
+		t.Errorf("wrong code: got status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := oidcLink(t, app, res.LinkToken, "000000"); status != http.StatusBadRequest {
+		t.Errorf("reused link token: got status %d, want %d", status, http.StatusBadRequest)
+	}
+
+	_, res = oidcSignIn(t, app)
+	code, err := totp.Code(secret, totp.Counter(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, linked := oidcLink(t, app, res.LinkToken, code)
+	if status != http.StatusCreated || linked.User.ID != user.ID {
+		t.Fatalf("right code: got status %d and user %d, want %d and user %d", status, linked.User.ID, http.StatusCreated, user.ID)
+	}
+
+	// Once linked, the provider is trusted with the second factor.
+	if status, _ := oidcSignIn(t, app); status != http.StatusCreated {
+		t.Errorf("sign in after linking: got status %d, want %d", status, http.StatusCreated)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+func (app *application) showCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.fullUser(r)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	profile, err := app.models.ProfileModel.Get(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	env := envelope{"user": user, "profile": profile}
+	deletion, err := app.models.AccountDeletionModel.Get(user.ID)
+	switch {
+	case err == nil:
+		env["deletion"] = deletion
+	case !errors.Is(err, data.ErrNoRows):
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, env, http.StatusOK)
+}
+
+// updateProfileHandler changes the fields of the profile present in the request. An
+// empty username removes the public author page.
+func (app *application) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Username    *string `json:"username"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		Avatar      *string `json:"avatar"`
+		Website     *string `json:"website"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	profile, err := app.models.ProfileModel.Get(app.contextGetUser(r).ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if input.Username != nil {
+		profile.Username = strings.ToLower(strings.TrimSpace(*input.Username))
+	}
+	if input.DisplayName != nil {
+		profile.DisplayName = strings.TrimSpace(*input.DisplayName)
+	}
+	if input.Bio != nil {
+		profile.Bio = strings.TrimSpace(*input.Bio)
+	}
+	if input.Avatar != nil {
+		profile.Avatar = strings.TrimSpace(*input.Avatar)
+	}
+	if input.Website != nil {
+		profile.Website = strings.TrimSpace(*input.Website)
+	}
+
+	v := validator.New()
+	if data.ValidateProfile(v, profile); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	err = app.models.ProfileModel.Update(profile)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateUsername):
+			v.AddErrorMessage("username", "is already taken")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	app.writeJSON(w, r, envelope{"profile": profile}, http.StatusOK)
+}
+
+// showAuthorHandler is the public page of an author: their profile, counts and a page
+// of their posts.
+func (app *application) showAuthorHandler(w http.ResponseWriter, r *http.Request) {
+	username := httprouter.ParamsFromContext(r.Context()).ByName("username")
+
+	v := validator.New()
+	qs := r.URL.Query()
+	filters := data.Filters{
+		Page:     app.readIntQuery(qs, "page", 1, v),
+		PageSize: app.readIntQuery(qs, "page_size", 20, v),
+	}
+	if data.ValidateFilters(v, filters); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	profile, counts, err := app.models.ProfileModel.GetAuthor(strings.ToLower(username))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	posts, metadata, err := app.models.BlogModel.GetAllForUser(profile.UserID, filters)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	env := envelope{
+		"author":   profile,
+		"counts":   counts,
+		"posts":    posts,
+		"metadata": metadata,
+	}
+	app.writeJSON(w, r, env, http.StatusOK)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/sulavmhrzn/goblog/internal/data"
 )
 
 func (app *application) router() http.Handler {
@@ -12,16 +13,95 @@ func (app *application) router() http.Handler {
 	router.HandlerFunc(http.MethodPost, "/api/v1/users/", app.createUserHandler)
 
 	router.HandlerFunc(http.MethodPost, "/api/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodDelete, "/api/v1/tokens/authentication", app.requireAuthenticatedUser(app.deleteAuthenticationTokenHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/tokens/refresh", app.refreshTokenHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/oidc/:provider/login", app.oidcLoginHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/oidc/:provider/callback", app.oidcCallbackHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/oidc/:provider/link", app.linkIdentityHandler)
 	router.HandlerFunc(http.MethodPost, "/api/v1/tokens/activate", app.createActivationTokenHandler)
 	router.HandlerFunc(http.MethodPost, "/api/v1/tokens/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
+	router.HandlerFunc(http.MethodPut, "/api/v1/users/password", app.updateUserPasswordHandler)
+	router.HandlerFunc(http.MethodPut, "/api/v1/users/me/password", app.requireActivatedUser(app.changePasswordHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/users/me/email", app.requireActivatedUser(app.changeEmailHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/tokens/email-change", app.confirmEmailChangeHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/users/me/2fa", app.requireActivatedUser(app.enrollTwoFactorHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/users/me/2fa/confirm", app.requireActivatedUser(app.confirmTwoFactorHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/users/me/2fa", app.requireActivatedUser(app.disableTwoFactorHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/users/me/sessions", app.requireAuthenticatedUser(app.listSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/users/me/sessions", app.requireAuthenticatedUser(app.deleteAllSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/users/me/sessions/:id", app.requireAuthenticatedUser(app.deleteSessionHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/users/me", app.requireAuthenticatedUser(app.showCurrentUserHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/users/me", app.requireActivatedUser(app.updateProfileHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/users/me", app.requireAuthenticatedUser(app.deleteAccountHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/users/me/deletion", app.requireAuthenticatedUser(app.cancelAccountDeletionHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/users/me/export", app.requireAuthenticatedUser(app.requestDataExportHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/exports/download", app.downloadDataExportHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/authors/:username", app.showAuthorHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/users/me/api-keys", app.requireActivatedUser(app.listAPIKeysHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/users/me/api-keys", app.requireActivatedUser(app.createAPIKeyHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/users/me/api-keys/:id", app.requireActivatedUser(app.deleteAPIKeyHandler))
 
-	router.HandlerFunc(http.MethodPost, "/api/v1/blogs", app.requireActivatedUser(app.createBlogHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/blogs", app.requirePermission(data.PermissionBlogsWrite, app.createBlogHandler))
 	router.HandlerFunc(http.MethodGet, "/api/v1/blogs", app.listBlogsHandler)
 	router.HandlerFunc(http.MethodGet, "/api/v1/blogs/:id", app.getBlogHandler)
-	router.HandlerFunc(http.MethodDelete, "/api/v1/blogs/:id", app.requireActivatedUser(app.deleteBlogHandler))
-	router.HandlerFunc(http.MethodPut, "/api/v1/blogs/:id", app.requireActivatedUser(app.updateBlogHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/blogs/:id", app.requirePermission(data.PermissionBlogsWrite, app.deleteBlogHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/blogs/:id", app.requirePermission(data.PermissionBlogsWrite, app.updateBlogHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/blogs/:id/translations", app.listTranslationsHandler)
+	router.HandlerFunc(http.MethodPut, "/api/v1/blogs/:id/translations/:locale", app.requireActivatedUser(app.upsertTranslationHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/blogs/:id/translations/:locale", app.requireActivatedUser(app.deleteTranslationHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/blogs/:id/comments", app.listCommentsHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/blogs/:id/comments", app.requireActivatedUser(app.createCommentHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/blogs/:id/bookmark", app.requireAuthenticatedUser(app.createBookmarkHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/blogs/:id/bookmark", app.requireAuthenticatedUser(app.deleteBookmarkHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/users/bookmarks", app.requireAuthenticatedUser(app.listBookmarksHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/blogs/:id/report", app.requireActivatedUser(app.createReportHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/moderation/reports", app.requirePermission(data.PermissionBlogsModerate, app.listReportsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/moderation/reports/:id/action", app.requirePermission(data.PermissionBlogsModerate, app.actOnReportHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/moderation/blogs/:id/unhide", app.requirePermission(data.PermissionBlogsModerate, app.unhidePostHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/moderation/users/:id/unsuspend", app.requirePermission(data.PermissionBlogsModerate, app.unsuspendUserHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/follows/:id", app.showFollowsHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/follows/:id", app.requireAuthenticatedUser(app.followUserHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/follows/:id", app.requireAuthenticatedUser(app.unfollowUserHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/feed", app.requireAuthenticatedUser(app.feedHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/notifications", app.requireAuthenticatedUser(app.listNotificationsHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/notifications/read", app.requireAuthenticatedUser(app.markNotificationsReadHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/notifications/preferences", app.requireAuthenticatedUser(app.showNotificationPreferencesHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/notifications/preferences", app.requireAuthenticatedUser(app.updateNotificationPreferencesHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/digest/unsubscribe", app.showDigestUnsubscribeHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/digest/unsubscribe", app.unsubscribeDigestHandler)
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/subscribers", app.createSubscriberHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/subscribers/confirm", app.confirmSubscriberHandler)
+	router.HandlerFunc(http.MethodPut, "/api/v1/subscribers/confirm", app.confirmSubscriberHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/subscribers/unsubscribe", app.deleteSubscriberHandler)
+	router.HandlerFunc(http.MethodDelete, "/api/v1/subscribers/unsubscribe", app.deleteSubscriberHandler)
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/stream", app.streamHandler)
 
 	router.HandlerFunc(http.MethodGet, "/api/v1/users/dashboard", app.requireAuthenticatedUser(app.dashboardHandler))
 
+	router.HandlerFunc(http.MethodPost, "/api/v1/webhooks", app.requireActivatedUser(app.createWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/webhooks", app.requireActivatedUser(app.listWebhooksHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/webhooks/:id", app.requireActivatedUser(app.deleteWebhookHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/webhooks/:id/deliveries", app.requireActivatedUser(app.listWebhookDeliveriesHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/users", app.requirePermission(data.PermissionUsersAdmin, app.listUsersHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/users/:id", app.requirePermission(data.PermissionUsersAdmin, app.showUserHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/admin/users/:id", app.requirePermission(data.PermissionUsersAdmin, app.deleteUserHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/admin/users/:id/activation", app.requirePermission(data.PermissionUsersAdmin, app.updateUserActivationHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/admin/users/:id/password-reset", app.requirePermission(data.PermissionUsersAdmin, app.forcePasswordResetHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/admin/users/:id/tokens", app.requirePermission(data.PermissionUsersAdmin, app.revokeUserTokensHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/admin/users/:id/lockout", app.requirePermission(data.PermissionUsersAdmin, app.unlockUserHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/audit-log", app.requirePermission(data.PermissionUsersAdmin, app.listAuditLogHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/admin/users/:id/roles", app.requirePermission(data.PermissionUsersAdmin, app.showUserRolesHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/admin/users/:id/roles", app.requirePermission(data.PermissionUsersAdmin, app.updateUserRolesHandler))
+
 	return app.panicRecovery(app.perClientRateLimiter(app.authenticate(router)))
 }
@@ -0,0 +1,80 @@
+package main
+
+import "net/http"
+
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	current, err := app.currentSession(r)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	sessions, err := app.models.TokenModel.GetSessionsForUser(user.ID, current)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"sessions": sessions}, http.StatusOK)
+}
+
+func (app *application) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return
+	}
+	user := app.contextGetUser(r)
+	result, err := app.models.TokenModel.DeleteSession(user.ID, id)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if result == 0 {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+	err = app.revokeAccessTokens(user.ID, id)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+// deleteAllSessionsHandler signs the user out everywhere, including the current session.
+func (app *application) deleteAllSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	err := app.signOut(user.ID, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+// currentSession returns the session family of the token the request was made with.
+func (app *application) currentSession(r *http.Request) (int, error) {
+	if claims := app.contextGetClaims(r); claims != nil {
+		return claims.SessionID, nil
+	}
+	return app.models.TokenModel.FamilyForToken(app.contextGetToken(r))
+}
+
+// signOut deletes the sessions of userID other than keepFamily, or all of them when it
+// is zero, and revokes the stateless access tokens issued to them.
+func (app *application) signOut(userID, keepFamily int) error {
+	families, err := app.models.TokenModel.DeleteSessionsForUser(userID, keepFamily)
+	if err != nil {
+		return err
+	}
+	if keepFamily == 0 {
+		return app.revokeAccessTokens(userID, 0)
+	}
+	for _, family := range families {
+		err = app.revokeAccessTokens(userID, family)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
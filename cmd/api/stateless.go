@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/jwt"
+)
+
+const revocationPollInterval = 30 * time.Second
+
+// revocationList caches the token revocations so stateless access tokens can be
+// checked without a query. Revocations made by other instances are picked up by
+// runRevocationSync.
+type revocationList struct {
+	mu       sync.RWMutex
+	users    map[int]data.Revocation
+	families map[int]data.Revocation
+}
+
+func newRevocationList() *revocationList {
+	return &revocationList{
+		users:    make(map[int]data.Revocation),
+		families: make(map[int]data.Revocation),
+	}
+}
+
+// add merges revocations into the list and forgets the ones that have expired.
+func (l *revocationList) add(revocations ...data.Revocation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, revocation := range revocations {
+		if revocation.Family != 0 {
+			l.families[revocation.Family] = revocation
+		} else if current, ok := l.users[revocation.UserID]; !ok || revocation.RevokedAt.After(current.RevokedAt) {
+			l.users[revocation.UserID] = revocation
+		}
+	}
+	now := time.Now()
+	for family, revocation := range l.families {
+		if !revocation.Expiry.After(now) {
+			delete(l.families, family)
+		}
+	}
+	for userID, revocation := range l.users {
+		if !revocation.Expiry.After(now) {
+			delete(l.users, userID)
+		}
+	}
+}
+
+// revoked reports whether the token of claims was revoked. Tokens are issued with a
+// millisecond timestamp, so only one issued in the same millisecond as a revocation
+// covering its user is treated as revoked without having been issued before it.
+func (l *revocationList) revoked(claims *jwt.Claims) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if revocation, ok := l.families[claims.SessionID]; ok && revocation.UserID == claims.Subject {
+		return true
+	}
+	revocation, ok := l.users[claims.Subject]
+	return ok && claims.IssuedAt <= jwt.NewTimestamp(revocation.RevokedAt)
+}
+
+func (app *application) loadRevocations() error {
+	revocations, err := app.models.RevocationModel.GetAllActive()
+	if err != nil {
+		return err
+	}
+	app.revocations.add(revocations...)
+	return nil
+}
+
+func (app *application) runRevocationSync() {
+	for {
+		time.Sleep(revocationPollInterval)
+		err := app.loadRevocations()
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+	}
+}
+
+// revokeAccessTokens makes the stateless access tokens of a session of userID, or of
+// all of them when family is zero, unusable. Database tokens are revoked by deleting
+// them, so without stateless tokens this does nothing.
+func (app *application) revokeAccessTokens(userID, family int) error {
+	if app.jwt == nil {
+		return nil
+	}
+	now := time.Now()
+	revocation := &data.Revocation{
+		UserID:    userID,
+		Family:    family,
+		RevokedAt: now,
+		Expiry:    now.Add(app.config.tokens.accessTTL),
+	}
+	err := app.models.RevocationModel.Insert(revocation)
+	if err != nil {
+		return err
+	}
+	app.revocations.add(*revocation)
+	return nil
+}
+
+// newSession signs user in with a new session, or the session family when it isn't
+// zero. In stateless mode the access token is a JWT carrying the activation state and
+// permissions of user, and only the refresh token is stored.
+func (app *application) newSession(r *http.Request, user *data.User, family int) (*data.TokenPair, error) {
+	if app.jwt == nil {
+		return app.models.TokenModel.NewSession(user.ID, family, app.config.tokens.accessTTL, app.config.tokens.refreshTTL, app.clientIP(r), app.clientUserAgent(r))
+	}
+
+	permissions, err := app.models.PermissionModel.GetAllForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := app.models.TokenModel.NewRefreshToken(user.ID, family, app.config.tokens.refreshTTL, app.clientIP(r), app.clientUserAgent(r))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	access := &data.Token{
+		UserID: user.ID,
+		Expiry: now.Add(app.config.tokens.accessTTL),
+		Scope:  data.ScopeAuthentication,
+		Family: refresh.Family,
+	}
+	access.Plaintext, err = app.jwt.Sign(jwt.Claims{
+		Subject:   user.ID,
+		SessionID: refresh.Family,
+		IssuedAt:  jwt.NewTimestamp(now),
+		Expiry:    access.Expiry.Unix(),
+		Activated: user.Activated,
+		Scopes:    permissions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &data.TokenPair{Access: access, Refresh: refresh}, nil
+}
+
+// fullUser returns the user of the request with every field loaded. Stateless access
+// tokens only carry the ID and activation state, so for those the user is fetched.
+func (app *application) fullUser(r *http.Request) (*data.User, error) {
+	user := app.contextGetUser(r)
+	if app.contextGetClaims(r) == nil {
+		return user, nil
+	}
+	return app.models.UserModel.Get(user.ID)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/jwt"
+)
+
+func TestRevocationListRevoked(t *testing.T) {
+	revokedAt := time.Date(2024, 5, 1, 12, 0, 0, 400*int(time.Millisecond), time.UTC)
+	l := newRevocationList()
+	l.add(
+		data.Revocation{UserID: 1, RevokedAt: revokedAt, Expiry: time.Now().Add(time.Hour)},
+		data.Revocation{UserID: 2, Family: 9, RevokedAt: revokedAt, Expiry: time.Now().Add(time.Hour)},
+	)
+
+	tests := []struct {
+		name     string
+		claims   jwt.Claims
+		expected bool
+	}{
+		{"issued earlier", jwt.Claims{Subject: 1, IssuedAt: jwt.NewTimestamp(revokedAt.Add(-time.Second))}, true},
+		{"issued earlier in the same second", jwt.Claims{Subject: 1, IssuedAt: jwt.NewTimestamp(revokedAt.Add(-300 * time.Millisecond))}, true},
+		{"issued later in the same second", jwt.Claims{Subject: 1, IssuedAt: jwt.NewTimestamp(revokedAt.Add(300 * time.Millisecond))}, false},
+		{"issued later", jwt.Claims{Subject: 1, IssuedAt: jwt.NewTimestamp(revokedAt.Add(time.Minute))}, false},
+		{"revoked session", jwt.Claims{Subject: 2, SessionID: 9, IssuedAt: jwt.NewTimestamp(revokedAt.Add(time.Minute))}, true},
+		{"other session", jwt.Claims{Subject: 2, SessionID: 8, IssuedAt: jwt.NewTimestamp(revokedAt.Add(-time.Minute))}, false},
+		{"session of another user", jwt.Claims{Subject: 3, SessionID: 9}, false},
+	}
+	for _, tt := range tests {
+		claims := tt.claims
+		if got := l.revoked(&claims); got != tt.expected {
+			t.Errorf("%s: revoked = %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/broadcast"
+)
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	streamWriteTimeout      = 10 * time.Second
+)
+
+// streamLimiter caps the number of concurrent stream connections, both in total and per client ip.
+type streamLimiter struct {
+	mu        sync.Mutex
+	total     int
+	perClient map[string]int
+	maxTotal  int
+	maxClient int
+}
+
+func newStreamLimiter(maxTotal, maxClient int) *streamLimiter {
+	return &streamLimiter{
+		perClient: make(map[string]int),
+		maxTotal:  maxTotal,
+		maxClient: maxClient,
+	}
+}
+
+func (l *streamLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.total >= l.maxTotal || l.perClient[ip] >= l.maxClient {
+		return false
+	}
+	l.total++
+	l.perClient[ip]++
+	return true
+}
+
+func (l *streamLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	l.perClient[ip]--
+	if l.perClient[ip] <= 0 {
+		delete(l.perClient, ip)
+	}
+}
+
+// publishBlogEvent pushes a blog event to every connected stream client.
+func (app *application) publishBlogEvent(event string, payload interface{}) {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		app.errorlog.Println(err)
+		return
+	}
+	app.broker.Publish(event, js)
+}
+
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		app.internalServerErrorResponse(w, r, "streaming unsupported by response writer")
+		return
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !app.streams.acquire(ip) {
+		app.rateLimitErrorResponse(w, r)
+		return
+	}
+	defer app.streams.release(ip)
+
+	var lastEventID int64
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		lastEventID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			app.badRequestErrorResponse(w, r, "Last-Event-ID must be an integer")
+			return
+		}
+	}
+
+	events, replay, unsubscribe := app.broker.Subscribe(lastEventID)
+	defer unsubscribe()
+
+	// The server wide WriteTimeout would cut the stream off after a few seconds,
+	// so the deadline is pushed forward before every write instead.
+	rc := http.NewResponseController(w)
+	write := func(format string, args ...interface{}) error {
+		err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, format, args...)
+		if err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	writeEvent := func(event broadcast.Event) error {
+		return write("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if write("retry: 3000\n\n") != nil {
+		return
+	}
+	for _, event := range replay {
+		if writeEvent(event) != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	// Connections are closed after maxDuration so clients reconnect and get rebalanced.
+	deadline := time.NewTimer(app.config.stream.maxDuration)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			return
+		case <-heartbeat.C:
+			if write(": ping\n\n") != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				// The broker dropped us for falling behind; the client will resume with Last-Event-ID.
+				return
+			}
+			if writeEvent(event) != nil {
+				return
+			}
+		}
+	}
+}
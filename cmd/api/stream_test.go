@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestStreamLimiter(t *testing.T) {
+	l := newStreamLimiter(3, 2)
+
+	steps := []struct {
+		acquire bool
+		ip      string
+		want    bool
+	}{
+		{true, "10.0.0.1", true},
+		{true, "10.0.0.1", true},
+		{true, "10.0.0.1", false}, // per client limit
+		{true, "10.0.0.2", true},
+		{true, "10.0.0.3", false}, // total limit
+		{false, "10.0.0.1", true},
+		{true, "10.0.0.3", true},
+		{true, "10.0.0.1", false}, // total limit again
+	}
+	for i, step := range steps {
+		if !step.acquire {
+			l.release(step.ip)
+			continue
+		}
+		if got := l.acquire(step.ip); got != step.want {
+			t.Fatalf("step %d: acquire(%s) = %v, want %v", i, step.ip, got, step.want)
+		}
+	}
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		l.release(ip)
+	}
+	if l.total != 0 || len(l.perClient) != 0 {
+		t.Fatalf("got total %d and %d clients after releasing everything, want none", l.total, len(l.perClient))
+	}
+}
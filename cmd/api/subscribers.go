@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+const subscriptionTokenTTL = 72 * time.Hour
+
+func (app *application) createSubscriberHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email    string `json:"email"`
+		AuthorID *int   `json:"author_id"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	// Addresses are stored lowercased so one reader cannot hold several subscriptions
+	// that only differ in case.
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+	v := validator.New()
+	if data.ValidateEmail(v, input.Email); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+	if input.AuthorID != nil {
+		_, err := app.models.UserModel.Get(*input.AuthorID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrNoRows):
+				v.AddErrorMessage("author_id", "does not exist")
+				app.failedValidationCheckErrorResponse(w, r, v.Error)
+			default:
+				app.internalServerErrorResponse(w, r, err.Error())
+			}
+			return
+		}
+	}
+
+	subscriber := &data.Subscriber{Email: input.Email, AuthorID: input.AuthorID}
+	token, err := app.models.SubscriberModel.Subscribe(subscriber, subscriptionTokenTTL)
+	if err != nil && !errors.Is(err, data.ErrAlreadyConfirmed) {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	// Already confirmed addresses get the same response but no email, so the
+	// endpoint cannot be used to find out who is subscribed.
+	if token != nil {
+		app.background(func() {
+			body := fmt.Sprintf("Please confirm your Goblog subscription with this token: %s\n\nOr open: %s/api/v1/subscribers/confirm?token=%s",
+				token.Plaintext, app.config.baseURL, url.QueryEscape(token.Plaintext))
+			err := app.mailer.Send(subscriber.Email, "Confirm your Goblog subscription", body)
+			if err != nil {
+				app.errorlog.Println(err)
+			}
+		})
+	}
+	app.writeJSON(w, r, envelope{"message": "please check your inbox to confirm your subscription"}, http.StatusAccepted)
+}
+
+// confirmSubscriberHandler completes the double opt-in. The token is read from the
+// JSON body, or from the query string when following the link in the email.
+func (app *application) confirmSubscriberHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+	if r.Method == http.MethodGet {
+		input.TokenPlaintext = r.URL.Query().Get("token")
+	} else {
+		err := app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestErrorResponse(w, r, err.Error())
+			return
+		}
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	subscriber, err := app.models.SubscriberModel.Confirm(input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			v.AddErrorMessage("token", "invalid or expired confirmation token")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	app.writeJSON(w, r, envelope{"subscriber": subscriber}, http.StatusOK)
+}
+
+func (app *application) deleteSubscriberHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		app.badRequestErrorResponse(w, r, "key must be provided")
+		return
+	}
+	result, err := app.models.SubscriberModel.DeleteByKey(key)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if result == 0 {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+	app.writeJSON(w, r, envelope{"message": "you have been unsubscribed from all Goblog newsletters"}, http.StatusOK)
+}
+
+// sendNewsletter emails a newly published blog to its confirmed subscribers in
+// batches, pausing between batches to stay under the SMTP provider's rate limits.
+func (app *application) sendNewsletter(blog *data.Blog) {
+	app.background(func() {
+		postURL := fmt.Sprintf("%s/api/v1/blogs/%d", app.config.baseURL, blog.ID)
+		after := ""
+		for {
+			subscribers, err := app.models.SubscriberModel.GetConfirmedForAuthor(blog.UserID, after, app.config.newsletter.batchSize)
+			if err != nil {
+				app.errorlog.Println(err)
+				return
+			}
+			for _, s := range subscribers {
+				err := app.mailer.SendTemplate(s.Email, "newsletter.tmpl", map[string]interface{}{
+					"Blog":           blog,
+					"PostURL":        postURL,
+					"UnsubscribeURL": app.config.baseURL + "/api/v1/subscribers/unsubscribe?key=" + url.QueryEscape(s.UnsubscribeKey),
+				})
+				if err != nil {
+					app.errorlog.Println(err)
+				}
+			}
+			if len(subscribers) < app.config.newsletter.batchSize {
+				return
+			}
+			after = subscribers[len(subscribers)-1].Email
+			time.Sleep(app.config.newsletter.batchInterval)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -13,6 +14,7 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	var input struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		TOTPCode string `json:"totp_code"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -29,11 +31,21 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 
+	lockedUntil, err := app.loginLockedUntil(r, input.Email)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !lockedUntil.IsZero() {
+		app.loginLockedErrorResponse(w, r, lockedUntil)
+		return
+	}
+
 	user, err := app.models.UserModel.GetByEmail(input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRows):
-			app.invalidCredentialsErrorResponse(w, r)
+			app.failedLoginResponse(w, r, input.Email, nil)
 			return
 		default:
 			app.internalServerErrorResponse(w, r, err.Error())
@@ -47,15 +59,46 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 	if !match {
-		app.invalidCredentialsErrorResponse(w, r)
+		app.failedLoginResponse(w, r, input.Email, user)
+		return
+	}
+	if user.Suspended {
+		app.suspendedAccountErrorResponse(w, r)
+		return
+	}
+
+	enrollment, err := app.models.TOTPModel.Get(user.ID)
+	if err != nil && !errors.Is(err, data.ErrNoRows) {
+		app.internalServerErrorResponse(w, r, err.Error())
 		return
 	}
-	token, err := app.models.TokenModel.New(user.ID, 24*time.Hour, data.ScopeAuthentication)
+	if enrollment != nil && enrollment.Enabled {
+		if input.TOTPCode == "" {
+			app.twoFactorRequiredErrorResponse(w, r)
+			return
+		}
+		ok, err := app.verifySecondFactor(enrollment, input.TOTPCode, true)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+		if !ok {
+			app.failedLoginResponse(w, r, input.Email, user)
+			return
+		}
+	}
+
+	err = app.models.LoginFailureModel.Delete(data.LoginFailureEmail, loginSubject(input.Email))
 	if err != nil {
 		app.internalServerErrorResponse(w, r, err.Error())
 		return
 	}
-	err = app.writeJSON(w, r, envelope{"authentication_token": token}, http.StatusCreated)
+	tokens, err := app.newSession(r, user, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.writeJSON(w, r, envelope{"authentication_token": tokens.Access, "refresh_token": tokens.Refresh}, http.StatusCreated)
 	if err != nil {
 		app.internalServerErrorResponse(w, r, err.Error())
 	}
@@ -82,11 +125,22 @@ func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *h
 		return
 	}
 
+	// Checking the password here would otherwise be a way around the login lockout.
+	lockedUntil, err := app.loginLockedUntil(r, input.Email)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !lockedUntil.IsZero() {
+		app.loginLockedErrorResponse(w, r, lockedUntil)
+		return
+	}
+
 	user, err := app.models.UserModel.GetByEmail(input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRows):
-			app.invalidCredentialsErrorResponse(w, r)
+			app.failedLoginResponse(w, r, input.Email, nil)
 		default:
 			app.internalServerErrorResponse(w, r, err.Error())
 		}
@@ -99,7 +153,7 @@ func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *h
 		return
 	}
 	if !match {
-		app.invalidCredentialsErrorResponse(w, r)
+		app.failedLoginResponse(w, r, input.Email, user)
 		return
 	}
 	token, err := app.models.TokenModel.New(user.ID, 24*time.Hour, data.ScopeActivation)
@@ -154,8 +208,150 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		app.internalServerErrorResponse(w, r, err.Error())
 		return
 	}
+	// Stateless access tokens still claim the account is inactive until they are refreshed.
+	err = app.revokeAccessTokens(user.ID, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.triggerWebhookEvent(user.ID, data.EventUserActivated, user)
 	err = app.writeJSON(w, r, envelope{"user": user}, http.StatusOK)
 	if err != nil {
 		app.internalServerErrorResponse(w, r, err.Error())
 	}
 }
+
+const passwordResetTTL = 45 * time.Minute
+
+// createPasswordResetTokenHandler emails a password reset token. The lookup happens in
+// the background and the response never changes, so it can't reveal which emails exist.
+func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateEmail(v, input.Email); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	app.background(func() {
+		user, err := app.models.UserModel.GetByEmail(input.Email)
+		if err != nil {
+			if !errors.Is(err, data.ErrNoRows) {
+				app.errorlog.Println(err)
+			}
+			return
+		}
+		if user.Suspended {
+			return
+		}
+		err = app.sendPasswordReset(user, passwordResetTTL)
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+	})
+
+	message := "if an account with that email exists, a password reset token has been sent to it"
+	app.writeJSON(w, r, envelope{"message": message}, http.StatusAccepted)
+}
+
+// sendPasswordReset creates a password reset token for user and emails it.
+func (app *application) sendPasswordReset(user *data.User, ttl time.Duration) error {
+	token, err := app.models.TokenModel.New(user.ID, ttl, data.ScopePasswordReset)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Your password reset token is: %s\n\nIt expires at %s. If you did not ask for it, you can ignore this email.", token.Plaintext, token.Expiry.UTC().Format(time.RFC1123))
+	return app.mailer.Send(user.Email, "Reset your Goblog password", body)
+}
+
+func (app *application) deleteAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	family, err := app.currentSession(r)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	_, err = app.models.TokenModel.DeleteSession(user.ID, family)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.revokeAccessTokens(user.ID, family)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+// refreshTokenHandler exchanges a refresh token for a new access and refresh token in
+// the same session. Each refresh token works once; see TokenModel.UseRefreshToken.
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"refresh_token"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	userID, family, err := app.models.TokenModel.UseRefreshToken(input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRefreshTokenReused):
+			app.errorlog.Printf("refresh token of session %d reused, the session was revoked", family)
+			err = app.revokeAccessTokens(userID, family)
+			if err != nil {
+				app.errorlog.Println(err)
+			}
+			app.invalidAuthenticationTokenErrorResponse(w, r)
+		case errors.Is(err, data.ErrNoRows):
+			app.invalidAuthenticationTokenErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+
+	user, err := app.models.UserModel.Get(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.invalidAuthenticationTokenErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	if user.Suspended {
+		app.suspendedAccountErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.TokenModel.DeleteAccessTokensForFamily(family)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	tokens, err := app.newSession(r, user, family)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"authentication_token": tokens.Access, "refresh_token": tokens.Refresh}, http.StatusCreated)
+}
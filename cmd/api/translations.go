@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+// requestedLocales returns the languages asked for by the client, most preferred first.
+// An explicit ?lang= takes precedence over the Accept-Language header.
+func requestedLocales(r *http.Request) []string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return []string{lang}
+	}
+
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				value, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err == nil {
+					q = value
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	locales := make([]string, len(tags))
+	for i, t := range tags {
+		locales[i] = t.tag
+	}
+	return locales
+}
+
+// negotiateLocale picks the first requested locale that is available, matching
+// exactly first and then on the primary language subtag, so "ne-NP" matches "ne".
+func negotiateLocale(requested, available []string) (string, bool) {
+	for _, want := range requested {
+		for _, have := range available {
+			if strings.EqualFold(want, have) {
+				return have, true
+			}
+		}
+		base := strings.SplitN(want, "-", 2)[0]
+		for _, have := range available {
+			if strings.EqualFold(base, strings.SplitN(have, "-", 2)[0]) {
+				return have, true
+			}
+		}
+	}
+	return "", false
+}
+
+// localizeBlogs lists the available translations on each blog and swaps in the
+// translation that best matches the request, leaving the original as the fallback.
+func (app *application) localizeBlogs(r *http.Request, blogs []data.Blog) error {
+	if len(blogs) == 0 {
+		return nil
+	}
+	ids := make([]int, len(blogs))
+	for i := range blogs {
+		ids[i] = blogs[i].ID
+	}
+	translations, err := app.models.TranslationModel.GetAllForBlogs(ids)
+	if err != nil {
+		return err
+	}
+
+	requested := requestedLocales(r)
+	for i := range blogs {
+		b := &blogs[i]
+		b.Translations = []data.TranslationRef{{Locale: b.Locale, Slug: b.Slug, Original: true}}
+		available := []string{b.Locale}
+		for _, t := range translations[b.ID] {
+			b.Translations = append(b.Translations, data.TranslationRef{Locale: t.Locale, Slug: t.Slug})
+			available = append(available, t.Locale)
+		}
+
+		locale, ok := negotiateLocale(requested, available)
+		if !ok || locale == b.Locale {
+			continue
+		}
+		for _, t := range translations[b.ID] {
+			if t.Locale == locale {
+				t.Apply(b)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (app *application) upsertTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	blog, ok := app.ownedBlogForRequest(w, r)
+	if !ok {
+		return
+	}
+	var input struct {
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	translation := &data.BlogTranslation{
+		BlogID:  blog.ID,
+		Locale:  data.NormalizeLocale(httprouter.ParamsFromContext(r.Context()).ByName("locale")),
+		Title:   input.Title,
+		Content: input.Content,
+		Slug:    slug.Make(input.Title),
+	}
+	v := validator.New()
+	v.Check(!strings.EqualFold(translation.Locale, blog.Locale), "locale", "must differ from the original language of the blog")
+	if data.ValidateBlogTranslation(v, translation); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	err = app.models.TranslationModel.Upsert(translation)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"translation": translation}, http.StatusOK)
+}
+
+func (app *application) deleteTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	blog, ok := app.ownedBlogForRequest(w, r)
+	if !ok {
+		return
+	}
+	locale := data.NormalizeLocale(httprouter.ParamsFromContext(r.Context()).ByName("locale"))
+	result, err := app.models.TranslationModel.Delete(blog.ID, locale)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if result == 0 {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+func (app *application) listTranslationsHandler(w http.ResponseWriter, r *http.Request) {
+	blog, ok := app.visibleBlogForRequest(w, r)
+	if !ok {
+		return
+	}
+	translations, err := app.models.TranslationModel.GetAllForBlogs([]int{blog.ID})
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	list := translations[blog.ID]
+	if list == nil {
+		list = []data.BlogTranslation{}
+	}
+	app.writeJSON(w, r, envelope{"translations": list}, http.StatusOK)
+}
+
+// ownedBlogForRequest loads the blog named by the :id parameter and checks that the
+// current user may edit it. It writes the error response itself and returns false on failure.
+func (app *application) ownedBlogForRequest(w http.ResponseWriter, r *http.Request) (*data.Blog, bool) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return nil, false
+	}
+	blog, err := app.models.BlogModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return nil, false
+	}
+	allowed, err := app.canEditBlog(r, blog)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return nil, false
+	}
+	if !allowed {
+		app.unauthorizedErrorResponse(w, r)
+		return nil, false
+	}
+	return blog, true
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	available := []string{"en", "ne-NP", "fr"}
+	tests := []struct {
+		name           string
+		url            string
+		acceptLanguage string
+		want           string
+		ok             bool
+	}{
+		{"exact match", "/", "fr", "fr", true},
+		{"case-insensitive match", "/", "NE-np", "ne-NP", true},
+		{"primary subtag of requested", "/", "fr-CA", "fr", true},
+		{"primary subtag of available", "/", "ne", "ne-NP", true},
+		{"highest q-value wins", "/", "fr;q=0.5, ne-NP;q=0.9, en;q=0.7", "ne-NP", true},
+		{"order breaks q-value ties", "/", "en, fr", "en", true},
+		{"missing q-value means 1", "/", "fr;q=0.8, en", "en", true},
+		{"unavailable skipped", "/", "de, fr;q=0.3", "fr", true},
+		{"q=0 refuses", "/", "fr;q=0, en;q=0.1", "en", true},
+		{"wildcard ignored", "/", "*", "", false},
+		{"nothing available", "/", "de, ja;q=0.8", "", false},
+		{"no header", "/", "", "", false},
+		{"lang parameter overrides header", "/?lang=fr", "en", "fr", true},
+		{"unavailable lang parameter", "/?lang=de", "en", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			got, ok := negotiateLocale(requestedLocales(r), available)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/totp"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+const totpIssuer = "Goblog"
+
+// enrollTwoFactorHandler starts a TOTP enrollment. It asks for the current password so
+// a stolen access token alone cannot put a second factor on the account. Two-factor
+// authentication is only enforced once confirmTwoFactorHandler receives a code
+// generated from the secret.
+func (app *application) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Password != "", "password", "must be provided")
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user, err := app.fullUser(r)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !app.checkPassword(w, r, user, input.Password, "password") {
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	enrolled, err := app.models.TOTPModel.Enroll(user.ID, secret)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !enrolled {
+		v.AddErrorMessage("two_factor", "is already enabled")
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+	env := envelope{
+		"secret": secret,
+		"uri":    totp.URI(totpIssuer, user.Email, secret),
+	}
+	app.writeJSON(w, r, env, http.StatusCreated)
+}
+
+func (app *application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	user := app.contextGetUser(r)
+	v := validator.New()
+	enrollment, err := app.models.TOTPModel.Get(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			v.AddErrorMessage("two_factor", "has not been enrolled")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	if enrollment.Enabled {
+		v.AddErrorMessage("two_factor", "is already enabled")
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+	ok, err := app.verifySecondFactor(enrollment, input.Code, false)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !ok {
+		v.AddErrorMessage("code", "is invalid or expired")
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	codes, err := data.NewRecoveryCodes()
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.RecoveryCodeModel.Replace(user.ID, codes)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.TOTPModel.Enable(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	// Sessions opened before the second factor was required are signed out.
+	err = app.revokeOtherSessions(r, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"recovery_codes": codes}, http.StatusOK)
+}
+
+func (app *application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Password != "", "password", "must be provided")
+	v.Check(input.Code != "", "code", "must be provided")
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user, err := app.fullUser(r)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	enrollment, err := app.models.TOTPModel.Get(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	if !app.checkPassword(w, r, user, input.Password, "password") {
+		return
+	}
+	ok, err := app.verifySecondFactor(enrollment, input.Code, enrollment.Enabled)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !ok {
+		err = app.recordLoginFailure(r, user.Email, user)
+		if err != nil {
+			app.internalServerErrorResponse(w, r, err.Error())
+			return
+		}
+		v.AddErrorMessage("code", "is invalid or expired")
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	err = app.models.TOTPModel.Delete(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.RecoveryCodeModel.DeleteAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+// verifySecondFactor checks code against the TOTP secret of enrollment, refusing codes
+// that were already used. When allowRecovery is set, an unused recovery code is also
+// accepted and consumed.
+func (app *application) verifySecondFactor(enrollment *data.TOTP, code string, allowRecovery bool) (bool, error) {
+	if counter, ok := totp.Match(enrollment.Secret, code, time.Now()); ok {
+		return app.models.TOTPModel.UseCounter(enrollment.UserID, counter)
+	}
+	if !allowRecovery || len(code) == totp.Digits {
+		return false, nil
+	}
+	return app.models.RecoveryCodeModel.Use(enrollment.UserID, code)
+}
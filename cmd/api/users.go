@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/sulavmhrzn/goblog/internal/data"
@@ -38,7 +39,7 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.models.UserModel.Insert(user)
+	err = app.models.UserModel.Insert(user, data.RoleAuthor)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -64,3 +65,271 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 	})
 	app.writeJSON(w, r, envelope{"data": user}, http.StatusOK)
 }
+
+func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password       string `json:"password"`
+		TokenPlaintext string `json:"token"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	data.ValidatePlaintextPassword(v, input.Password)
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext)
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user, err := app.models.UserModel.GetForToken(data.ScopePasswordReset, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			v.AddErrorMessage("token", "invalid or expired password reset token")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.UserModel.Update(user)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.TokenModel.DeleteAllForUser(data.ScopePasswordReset, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.signOut(user.ID, 0)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	// API keys work without the password, so they are revoked along with the sessions.
+	err = app.models.APIKeyModel.DeleteAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	message := "your password was successfully reset, and you were signed out everywhere and your API keys were revoked"
+	app.writeJSON(w, r, envelope{"message": message}, http.StatusOK)
+}
+
+func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.CurrentPassword != "", "current_password", "must be provided")
+	data.ValidatePlaintextPassword(v, input.NewPassword)
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user, err := app.fullUser(r)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	if !app.checkPassword(w, r, user, input.CurrentPassword, "current_password") {
+		return
+	}
+
+	err = user.Password.Set(input.NewPassword)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.UserModel.Update(user)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.revokeOtherSessions(r, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.APIKeyModel.DeleteAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.background(func() {
+		body := "The password of your Goblog account was just changed, your other sessions were signed out and your API keys were revoked.\n\nIf this wasn't you, reset your password straight away."
+		err := app.mailer.Send(user.Email, "Your Goblog password was changed", body)
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+	})
+	message := "your password was successfully changed, and your other sessions were signed out and your API keys were revoked"
+	app.writeJSON(w, r, envelope{"message": message}, http.StatusOK)
+}
+
+// changeEmailHandler emails a confirmation token to the new address. The email of
+// the account only changes once confirmEmailChangeHandler receives that token.
+func (app *application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	user, err := app.fullUser(r)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	v := validator.New()
+	data.ValidateEmail(v, input.Email)
+	v.Check(input.Password != "", "password", "must be provided")
+	v.Check(!strings.EqualFold(input.Email, user.Email), "email", "must be different from your current email")
+	if !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	if !app.checkPassword(w, r, user, input.Password, "password") {
+		return
+	}
+
+	err = app.models.UserModel.SetPendingEmail(user.ID, input.Email)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.models.TokenModel.DeleteAllForUser(data.ScopeEmailChange, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	token, err := app.models.TokenModel.New(user.ID, 24*time.Hour, data.ScopeEmailChange)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.background(func() {
+		body := fmt.Sprintf("Your email change confirmation token is: %s", token.Plaintext)
+		err := app.mailer.Send(input.Email, "Confirm your new Goblog email", body)
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+		body = fmt.Sprintf("Someone asked to change the email of your Goblog account to %s. "+
+			"The change only happens once it is confirmed from that address.\n\n"+
+			"If this wasn't you, change your password straight away.", input.Email)
+		err = app.mailer.Send(user.Email, "Your Goblog email is being changed", body)
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+	})
+	app.writeJSON(w, r, envelope{"message": "a confirmation token has been sent to your new email"}, http.StatusAccepted)
+}
+
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	user, err := app.models.UserModel.GetForToken(data.ScopeEmailChange, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			v.AddErrorMessage("token", "invalid or expired email change token")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	newEmail, err := app.models.UserModel.TakePendingEmail(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			v.AddErrorMessage("token", "invalid or expired email change token")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+
+	oldEmail := user.Email
+	user.Email = newEmail
+	err = app.models.UserModel.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddErrorMessage("email", "a user with this email address already exists")
+			app.failedValidationCheckErrorResponse(w, r, v.Error)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return
+	}
+	err = app.models.TokenModel.DeleteAllForUser(data.ScopeEmailChange, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	err = app.revokeOtherSessions(r, user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.background(func() {
+		body := fmt.Sprintf("The email of your Goblog account was changed to %s and your other sessions were signed out.\n\nIf this wasn't you, contact us straight away.", newEmail)
+		err := app.mailer.Send(oldEmail, "Your Goblog email was changed", body)
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+	})
+	app.writeJSON(w, r, envelope{"user": user}, http.StatusOK)
+}
+
+// revokeOtherSessions signs userID out of every session except the one the request
+// was made with, if it belongs to the same user.
+func (app *application) revokeOtherSessions(r *http.Request, userID int) error {
+	if app.contextGetUser(r).ID != userID {
+		return app.signOut(userID, 0)
+	}
+	current, err := app.currentSession(r)
+	if err != nil {
+		return err
+	}
+	return app.signOut(userID, current)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+const (
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookPollInterval = 5 * time.Second
+)
+
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestErrorResponse(w, r, err.Error())
+		return
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+
+	user := app.contextGetUser(r)
+	webhook := &data.Webhook{
+		UserID: user.ID,
+		URL:    input.URL,
+		Secret: hex.EncodeToString(secret),
+		Events: input.Events,
+	}
+
+	v := validator.New()
+	if data.ValidateWebhook(v, webhook); !v.IsValid() {
+		app.failedValidationCheckErrorResponse(w, r, v.Error)
+		return
+	}
+
+	err = app.models.WebhookModel.Insert(webhook)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	// The secret is only ever returned here, receivers need it to verify signatures.
+	app.writeJSON(w, r, envelope{"webhook": webhook}, http.StatusCreated)
+}
+
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	webhooks, err := app.models.WebhookModel.GetAllForUser(user.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"webhooks": webhooks}, http.StatusOK)
+}
+
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.webhookForRequest(w, r)
+	if !ok {
+		return
+	}
+	err := app.models.WebhookModel.Delete(webhook.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{}, http.StatusNoContent)
+}
+
+func (app *application) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := app.webhookForRequest(w, r)
+	if !ok {
+		return
+	}
+	deliveries, err := app.models.WebhookDeliveryModel.GetAllForWebhook(webhook.ID)
+	if err != nil {
+		app.internalServerErrorResponse(w, r, err.Error())
+		return
+	}
+	app.writeJSON(w, r, envelope{"deliveries": deliveries}, http.StatusOK)
+}
+
+// webhookForRequest loads the webhook named by the :id parameter and checks that it
+// belongs to the current user. It writes the error response itself and returns false on failure.
+func (app *application) webhookForRequest(w http.ResponseWriter, r *http.Request) (*data.Webhook, bool) {
+	id, err := app.readInt(r)
+	if id < 0 || err != nil {
+		app.badRequestErrorResponse(w, r, "invalid id parameter")
+		return nil, false
+	}
+	webhook, err := app.models.WebhookModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRows):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.internalServerErrorResponse(w, r, err.Error())
+		}
+		return nil, false
+	}
+	if webhook.UserID != app.contextGetUser(r).ID {
+		app.notFoundErrorResponse(w, r)
+		return nil, false
+	}
+	return webhook, true
+}
+
+// triggerWebhookEvent queues a delivery of event to every webhook of userID subscribed to it.
+func (app *application) triggerWebhookEvent(userID int, event string, payload interface{}) {
+	app.background(func() {
+		body, err := json.Marshal(map[string]interface{}{
+			"event":      event,
+			"created_at": time.Now().UTC(),
+			"data":       payload,
+		})
+		if err != nil {
+			app.errorlog.Println(err)
+			return
+		}
+		err = app.models.WebhookDeliveryModel.Enqueue(userID, event, body)
+		if err != nil {
+			app.errorlog.Println(err)
+		}
+	})
+}
+
+// runWebhookDeliveries polls for due deliveries and sends them, rescheduling failures
+// with exponential backoff until webhookMaxAttempts is reached.
+func (app *application) runWebhookDeliveries() {
+	for {
+		time.Sleep(webhookPollInterval)
+		deliveries, err := app.models.WebhookDeliveryModel.GetDue(20)
+		if err != nil {
+			app.errorlog.Println(err)
+			continue
+		}
+		for i := range deliveries {
+			app.deliverWebhook(&deliveries[i])
+		}
+	}
+}
+
+func (app *application) deliverWebhook(d *data.WebhookDelivery) {
+	status, err := app.webhooks.Deliver(d.URL, d.Secret, d.Event, d.ID, d.Payload)
+	recordDeliveryAttempt(d, status, err, time.Now())
+	err = app.models.WebhookDeliveryModel.Update(d)
+	if err != nil {
+		app.errorlog.Println(err)
+	}
+}
+
+// recordDeliveryAttempt updates d with the outcome of an attempt made at now. Failed
+// attempts are retried after webhookBaseBackoff, doubling every time, until
+// webhookMaxAttempts is reached.
+func recordDeliveryAttempt(d *data.WebhookDelivery, status int, err error, now time.Time) {
+	d.Attempts++
+	d.ResponseStatus = status
+	if err == nil {
+		d.Status = data.DeliverySucceeded
+		d.LastError = ""
+		d.DeliveredAt = &now
+		return
+	}
+	d.LastError = err.Error()
+	if d.Attempts >= webhookMaxAttempts {
+		d.Status = data.DeliveryFailed
+	} else {
+		d.NextAttemptAt = now.Add(webhookBaseBackoff << (d.Attempts - 1))
+	}
+}
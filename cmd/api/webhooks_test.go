@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/webhook"
+)
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	failures := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := webhook.New(srv.Client())
+	d := &data.WebhookDelivery{ID: 1, Event: data.EventBlogCreated, URL: srv.URL, Secret: "secret", Status: data.DeliveryPending}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for attempt, backoff := range []time.Duration{webhookBaseBackoff, 2 * webhookBaseBackoff} {
+		status, err := client.Deliver(d.URL, d.Secret, d.Event, d.ID, d.Payload)
+		recordDeliveryAttempt(d, status, err, now)
+		if d.Status != data.DeliveryPending || d.ResponseStatus != http.StatusServiceUnavailable || d.LastError == "" {
+			t.Fatalf("attempt %d: got %+v, want a pending delivery with the error recorded", attempt+1, d)
+		}
+		if !d.NextAttemptAt.Equal(now.Add(backoff)) {
+			t.Fatalf("attempt %d: next attempt at %v, want %v", attempt+1, d.NextAttemptAt, now.Add(backoff))
+		}
+	}
+
+	status, err := client.Deliver(d.URL, d.Secret, d.Event, d.ID, d.Payload)
+	recordDeliveryAttempt(d, status, err, now)
+	if d.Status != data.DeliverySucceeded || d.Attempts != 3 || d.LastError != "" || d.DeliveredAt == nil {
+		t.Fatalf("got %+v, want a succeeded delivery after 3 attempts", d)
+	}
+}
+
+func TestWebhookGivesUpAfterMaxAttempts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := webhook.New(srv.Client())
+	d := &data.WebhookDelivery{ID: 1, Event: data.EventBlogCreated, URL: srv.URL, Secret: "secret", Status: data.DeliveryPending}
+	for i := 0; i < webhookMaxAttempts; i++ {
+		if d.Status != data.DeliveryPending {
+			t.Fatalf("delivery stopped after %d attempts, want %d", d.Attempts, webhookMaxAttempts)
+		}
+		status, err := client.Deliver(d.URL, d.Secret, d.Event, d.ID, d.Payload)
+		recordDeliveryAttempt(d, status, err, time.Now())
+	}
+	if d.Status != data.DeliveryFailed || d.Attempts != webhookMaxAttempts {
+		t.Fatalf("got status %q after %d attempts, want %q after %d", d.Status, d.Attempts, data.DeliveryFailed, webhookMaxAttempts)
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/sulavmhrzn/goblog/internal/data"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+const (
+	manifestFile = ".export-manifest.json"
+	feedSize     = 20
+)
+
+// manifest records what the previous export rendered, so the next one can skip
+// posts whose content and tags have not changed.
+type manifest struct {
+	ExportedAt time.Time         `json:"exported_at"`
+	Posts      map[string]string `json:"posts"`
+}
+
+type page struct {
+	Root        string
+	Lang        string
+	GeneratedAt time.Time
+	Post        *data.Blog
+	Posts       []data.Blog
+	Tags        []data.Tag
+	Tag         *data.Tag
+	AuthorID    int
+}
+
+type stats struct {
+	rendered, unchanged, removed int
+	pagesWritten, pagesUnchanged int
+	pagesRemoved                 int
+}
+
+type exporter struct {
+	models    data.Models
+	cfg       config
+	templates map[string]*template.Template
+	now       time.Time
+	stats     stats
+}
+
+func newExporter(models data.Models, cfg config) (*exporter, error) {
+	funcs := template.FuncMap{
+		"postPath":   postPath,
+		"tagPath":    tagPath,
+		"paragraphs": paragraphs,
+	}
+	templates := make(map[string]*template.Template)
+	for _, name := range []string{"index", "post", "tag", "author"} {
+		tmpl, err := template.New(name).Funcs(funcs).ParseFS(templateFS, "templates/base.tmpl", "templates/"+name+".tmpl")
+		if err != nil {
+			return nil, err
+		}
+		templates[name] = tmpl
+	}
+	return &exporter{models: models, cfg: cfg, templates: templates, now: time.Now()}, nil
+}
+
+// postPath and tagPath run slugs through slug.Make again, as slugs from imports are
+// not guaranteed to be safe to use in file names.
+func postPath(b data.Blog) string {
+	s := slug.Make(b.Slug)
+	if s == "" {
+		return fmt.Sprintf("posts/%d.html", b.ID)
+	}
+	return fmt.Sprintf("posts/%d-%s.html", b.ID, s)
+}
+
+func tagPath(t data.Tag) string {
+	s := slug.Make(t.Slug)
+	if s == "" {
+		return fmt.Sprintf("tags/%d.html", t.ID)
+	}
+	return "tags/" + s + ".html"
+}
+
+// paragraphs splits post content on blank lines so it renders as readable HTML
+// without pulling in a Markdown renderer.
+func paragraphs(content string) []string {
+	var result []string
+	for _, p := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func (e *exporter) run() (*stats, error) {
+	blogs, err := e.models.BlogModel.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(blogs, func(i, j int) bool {
+		if blogs[i].CreatedAt.Equal(blogs[j].CreatedAt) {
+			return blogs[i].ID > blogs[j].ID
+		}
+		return blogs[i].CreatedAt.After(blogs[j].CreatedAt)
+	})
+	ids := make([]int, len(blogs))
+	for i := range blogs {
+		ids[i] = blogs[i].ID
+	}
+	tags, err := e.models.TagModel.GetAllForBlogs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := e.readManifest()
+	current := manifest{ExportedAt: e.now, Posts: make(map[string]string)}
+
+	for i := range blogs {
+		b := &blogs[i]
+		key := strconv.Itoa(b.ID)
+		fingerprint := postFingerprint(b, tags[b.ID])
+		current.Posts[key] = fingerprint
+
+		_, statErr := os.Stat(filepath.Join(e.cfg.out, postPath(*b)))
+		if !e.cfg.full && previous.Posts[key] == fingerprint && statErr == nil {
+			e.stats.unchanged++
+			continue
+		}
+		err := e.render("post", postPath(*b), page{Root: "../", Post: b, Tags: tags[b.ID]})
+		if err != nil {
+			return nil, err
+		}
+		e.stats.rendered++
+	}
+
+	keep := make(map[string]bool, len(blogs))
+	for _, b := range blogs {
+		keep[postPath(b)] = true
+	}
+	e.stats.removed, err = e.removeStale("posts", keep)
+	if err != nil {
+		return nil, err
+	}
+	err = e.renderListings(blogs, tags)
+	if err != nil {
+		return nil, err
+	}
+	err = e.writeFeed(blogs)
+	if err != nil {
+		return nil, err
+	}
+	return &e.stats, e.writeManifest(current)
+}
+
+// renderListings renders the index, tag and author pages. They are cheap to build,
+// so they are always rendered and only written when their content changed. Pages of
+// tags and authors left without posts are removed, so they stop listing posts that
+// were hidden or deleted.
+func (e *exporter) renderListings(blogs []data.Blog, tags map[int][]data.Tag) error {
+	err := e.render("index", "index.html", page{Root: "", Posts: blogs})
+	if err != nil {
+		return err
+	}
+
+	byTag := make(map[string][]data.Blog)
+	tagInfo := make(map[string]data.Tag)
+	byAuthor := make(map[int][]data.Blog)
+	for _, b := range blogs {
+		for _, t := range tags[b.ID] {
+			path := tagPath(t)
+			byTag[path] = append(byTag[path], b)
+			tagInfo[path] = t
+		}
+		byAuthor[b.UserID] = append(byAuthor[b.UserID], b)
+	}
+
+	rendered := make(map[string]bool)
+	for path, posts := range byTag {
+		t := tagInfo[path]
+		err := e.render("tag", path, page{Root: "../", Tag: &t, Posts: posts})
+		if err != nil {
+			return err
+		}
+		rendered[path] = true
+	}
+	for authorID, posts := range byAuthor {
+		path := fmt.Sprintf("authors/%d.html", authorID)
+		err := e.render("author", path, page{Root: "../", AuthorID: authorID, Posts: posts})
+		if err != nil {
+			return err
+		}
+		rendered[path] = true
+	}
+
+	for _, dir := range []string{"tags", "authors"} {
+		removed, err := e.removeStale(dir, rendered)
+		if err != nil {
+			return err
+		}
+		e.stats.pagesRemoved += removed
+	}
+	return nil
+}
+
+func (e *exporter) render(name, path string, p page) error {
+	p.GeneratedAt = e.now
+	if p.Lang == "" {
+		p.Lang = "en"
+		if p.Post != nil && p.Post.Locale != "" {
+			p.Lang = p.Post.Locale
+		}
+	}
+	buf := new(bytes.Buffer)
+	err := e.templates[name].ExecuteTemplate(buf, "base", p)
+	if err != nil {
+		return fmt.Errorf("rendering %s: %w", path, err)
+	}
+	return e.write(path, buf.Bytes(), name != "post")
+}
+
+// write stores content under the output directory. Listing pages embed the export
+// time, so for them only the part before the footer is compared to detect changes.
+func (e *exporter) write(path string, content []byte, listing bool) error {
+	full, err := e.outputPath(path)
+	if err != nil {
+		return err
+	}
+	if listing {
+		existing, err := os.ReadFile(full)
+		if err == nil && bytes.Equal(beforeFooter(existing), beforeFooter(content)) {
+			e.stats.pagesUnchanged++
+			return nil
+		}
+		e.stats.pagesWritten++
+	}
+	err = os.MkdirAll(filepath.Dir(full), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(full, content, 0o644)
+}
+
+// outputPath joins path to the output directory, refusing any path that would end
+// up outside of it.
+func (e *exporter) outputPath(path string) (string, error) {
+	full := filepath.Join(e.cfg.out, path)
+	rel, err := filepath.Rel(filepath.Clean(e.cfg.out), full)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("refusing to write %q outside of the output directory", path)
+	}
+	return full, nil
+}
+
+func beforeFooter(content []byte) []byte {
+	if i := bytes.Index(content, []byte("<footer>")); i >= 0 {
+		return content[:i]
+	}
+	return content
+}
+
+// removeStale deletes the files in dir of the output directory whose slash separated
+// path is not in keep, such as posts that no longer exist or whose slug changed. It
+// returns how many it deleted.
+func (e *exporter) removeStale(dir string, keep map[string]bool) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(e.cfg.out, dir))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if keep[dir+"/"+entry.Name()] {
+			continue
+		}
+		err := os.Remove(filepath.Join(e.cfg.out, dir, entry.Name()))
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func postFingerprint(b *data.Blog, tags []data.Tag) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s", b.UpdatedAt.UTC().Format(time.RFC3339Nano), b.Title, b.Slug, b.Locale)
+	for _, t := range tags {
+		fmt.Fprintf(h, "\x00%s\x00%s", t.Slug, t.Name)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (e *exporter) readManifest() manifest {
+	m := manifest{Posts: make(map[string]string)}
+	content, err := os.ReadFile(filepath.Join(e.cfg.out, manifestFile))
+	if err != nil {
+		return m
+	}
+	// A corrupt manifest only costs a full rebuild.
+	if json.Unmarshal(content, &m) != nil || m.Posts == nil {
+		return manifest{Posts: make(map[string]string)}
+	}
+	return m
+}
+
+func (e *exporter) writeManifest(m manifest) error {
+	content, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(e.cfg.out, 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(e.cfg.out, manifestFile), content, 0o644)
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description"`
+}
+
+func (e *exporter) writeFeed(blogs []data.Blog) error {
+	base := strings.TrimRight(e.cfg.baseURL, "/")
+	feed := rss{Version: "2.0", Channel: rssChannel{
+		Title:       "Goblog",
+		Link:        base + "/index.html",
+		Description: "Latest posts on Goblog",
+	}}
+	for i, b := range blogs {
+		if i == feedSize {
+			break
+		}
+		if i == 0 {
+			feed.Channel.LastBuildDate = b.UpdatedAt.UTC().Format(time.RFC1123Z)
+		}
+		link := base + "/" + postPath(b)
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       b.Title,
+			Link:        link,
+			GUID:        link,
+			PubDate:     b.CreatedAt.UTC().Format(time.RFC1123Z),
+			Description: strings.Join(paragraphs(b.Content), "\n\n"),
+		})
+	}
+	content, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return e.write("feed.xml", append([]byte(xml.Header), content...), true)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sulavmhrzn/goblog/internal/data"
+)
+
+func TestPathsStayInsideOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"post", postPath(data.Blog{ID: 1, Slug: "hello-world"}), "posts/1-hello-world.html"},
+		{"post without slug", postPath(data.Blog{ID: 2}), "posts/2.html"},
+		{"post traversal", postPath(data.Blog{ID: 3, Slug: "../../../etc/passwd"}), "posts/3-etc-passwd.html"},
+		{"post only dots", postPath(data.Blog{ID: 4, Slug: "../.."}), "posts/4.html"},
+		{"tag", tagPath(data.Tag{ID: 1, Slug: "go"}), "tags/go.html"},
+		{"tag traversal", tagPath(data.Tag{ID: 2, Slug: "../../x"}), "tags/x.html"},
+		{"tag only separators", tagPath(data.Tag{ID: 3, Slug: "/../"}), "tags/3.html"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	out := t.TempDir()
+	e := &exporter{cfg: config{out: out}}
+
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{"index.html", true},
+		{"posts/1-a.html", true},
+		{"posts/../index.html", true},
+		{"../x.html", false},
+		{"tags/../../x.html", false},
+		{"..", false},
+	}
+	for _, tt := range tests {
+		full, err := e.outputPath(tt.path)
+		if (err == nil) != tt.ok {
+			t.Errorf("outputPath(%q) returned err %v, want ok %v", tt.path, err, tt.ok)
+			continue
+		}
+		if tt.ok && full != filepath.Join(out, tt.path) {
+			t.Errorf("outputPath(%q) = %q", tt.path, full)
+		}
+	}
+}
+
+func TestRenderListingsRemovesEmptyTagAndAuthorPages(t *testing.T) {
+	out := t.TempDir()
+	e, err := newExporter(data.Models{}, config{out: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golang := data.Tag{ID: 1, Name: "Go", Slug: "go"}
+	rust := data.Tag{ID: 2, Name: "Rust", Slug: "rust"}
+	blogs := []data.Blog{
+		{ID: 1, UserID: 10, Title: "Go only", Slug: "go-only"},
+		{ID: 2, UserID: 20, Title: "Both", Slug: "both"},
+	}
+	tags := map[int][]data.Tag{1: {golang}, 2: {golang, rust}}
+	if err := e.renderListings(blogs, tags); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"tags/go.html", "tags/rust.html", "authors/10.html", "authors/20.html"} {
+		if _, err := os.Stat(filepath.Join(out, path)); err != nil {
+			t.Fatalf("%s was not rendered: %v", path, err)
+		}
+	}
+
+	// Hiding the only post tagged rust, and the only post of author 20, leaves them
+	// out of the next export.
+	if err := e.renderListings(blogs[:1], map[int][]data.Tag{1: {golang}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"tags/rust.html", "authors/20.html"} {
+		if _, err := os.Stat(filepath.Join(out, path)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s was not removed: %v", path, err)
+		}
+	}
+	for _, path := range []string{"tags/go.html", "authors/10.html"} {
+		content, err := os.ReadFile(filepath.Join(out, path))
+		if err != nil {
+			t.Fatalf("%s was removed: %v", path, err)
+		}
+		if strings.Contains(string(content), "Both") {
+			t.Errorf("%s still lists the hidden post", path)
+		}
+	}
+	if e.stats.pagesRemoved != 2 {
+		t.Errorf("got %d pages removed, want 2", e.stats.pagesRemoved)
+	}
+}
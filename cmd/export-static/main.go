@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/sulavmhrzn/goblog/internal/data"
+)
+
+type config struct {
+	dsn     string
+	out     string
+	baseURL string
+	full    bool
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.dsn, "dsn", os.Getenv("DB_DSN"), "Database DSN")
+	flag.StringVar(&cfg.out, "out", "./public", "Directory to write the static site to")
+	flag.StringVar(&cfg.baseURL, "base-url", "https://localhost", "Public URL the static site is served from, used in the RSS feed")
+	flag.BoolVar(&cfg.full, "full", false, "Re-render every post instead of only those changed since the last export")
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+
+	db, err := openDB(cfg.dsn)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+
+	exp, err := newExporter(data.NewModels(db), cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	stats, err := exp.run()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	fmt.Printf("static export to %s complete\n", cfg.out)
+	fmt.Printf("  posts:   %d rendered, %d unchanged, %d removed\n", stats.rendered, stats.unchanged, stats.removed)
+	fmt.Printf("  pages:   %d written, %d unchanged, %d removed\n", stats.pagesWritten, stats.pagesUnchanged, stats.pagesRemoved)
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err = db.PingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
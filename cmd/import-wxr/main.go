@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gosimple/slug"
+	_ "github.com/lib/pq"
+	"github.com/sulavmhrzn/goblog/internal/data"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+// Column sizes of the tables posts and tags are imported into.
+const (
+	maxTitleLength   = 200
+	maxPostSlugLen   = 200
+	maxTagNameLength = 100
+	maxTagSlugLen    = 100
+)
+
+type config struct {
+	dsn    string
+	file   string
+	dryRun bool
+}
+
+// report counts what an import created and skipped. In a dry run "created"
+// means "would be created".
+type report struct {
+	usersCreated, usersSkipped       int
+	postsCreated, postsSkipped       int
+	tagsAttached                     int
+	commentsCreated, commentsSkipped int
+	itemsIgnored                     int
+	// invalid lists the posts and tags that were skipped because they would not
+	// be valid in Goblog, with the reason.
+	invalid []string
+}
+
+type importer struct {
+	models  data.Models
+	dryRun  bool
+	report  report
+	authors map[string]*data.User
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.dsn, "dsn", os.Getenv("DB_DSN"), "Database DSN")
+	flag.StringVar(&cfg.file, "file", "", "Path to the WordPress WXR export")
+	flag.BoolVar(&cfg.dryRun, "dry-run", false, "Report what would be imported without writing anything")
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	if cfg.file == "" {
+		logger.Fatal("-file must be provided")
+	}
+
+	f, err := os.Open(cfg.file)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := parseWXR(f)
+	if err != nil {
+		logger.Fatalf("parsing %s: %v", cfg.file, err)
+	}
+
+	db, err := openDB(cfg.dsn)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+
+	imp := &importer{
+		models:  data.NewModels(db),
+		dryRun:  cfg.dryRun,
+		authors: make(map[string]*data.User),
+	}
+	err = imp.run(doc)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	imp.printReport()
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err = db.PingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// run imports authors, then published posts with their tags and approved comments.
+// Every record is looked up before it is created, so running the import again
+// only fills in whatever is missing.
+func (imp *importer) run(doc *wxr) error {
+	for _, author := range doc.Channel.Authors {
+		err := imp.importAuthor(author)
+		if err != nil {
+			return fmt.Errorf("author %q: %w", author.Login, err)
+		}
+	}
+	for _, item := range doc.Channel.Items {
+		err := imp.importItem(item)
+		if err != nil {
+			return fmt.Errorf("post %d %q: %w", item.PostID, item.Title, err)
+		}
+	}
+	return nil
+}
+
+func (imp *importer) importAuthor(author wxrAuthor) error {
+	email := strings.TrimSpace(author.Email)
+	if email == "" {
+		// WXR allows authors without an email; give them a stable placeholder.
+		email = slug.Make(author.Login) + "@wordpress-import.invalid"
+	}
+
+	user, err := imp.models.UserModel.GetByEmail(email)
+	switch {
+	case err == nil:
+		imp.report.usersSkipped++
+		imp.authors[author.Login] = user
+		return nil
+	case !errors.Is(err, data.ErrNoRows):
+		return err
+	}
+
+	user = &data.User{Email: email}
+	imp.report.usersCreated++
+	imp.authors[author.Login] = user
+	if imp.dryRun {
+		return nil
+	}
+
+	// Imported authors get an unusable password and have to reset it to log in.
+	err = user.Password.SetUnusable()
+	if err != nil {
+		return err
+	}
+	return imp.models.UserModel.Insert(user, data.RoleAuthor)
+}
+
+func (imp *importer) importItem(item wxrItem) error {
+	if item.PostType != "post" || item.Status != "publish" {
+		imp.report.itemsIgnored++
+		return nil
+	}
+	author, ok := imp.authors[item.Creator]
+	if !ok {
+		return fmt.Errorf("unknown author %q", item.Creator)
+	}
+	createdAt, ok := parseWPDate(item.PostDate)
+	if !ok {
+		createdAt = time.Now()
+	}
+	// post_name comes straight from the export, so it is made into a slug like any
+	// title before it ends up in URLs and file paths.
+	postSlug := truncate(slug.Make(item.PostName), maxPostSlugLen)
+	if postSlug == "" {
+		postSlug = truncate(slug.Make(item.Title), maxPostSlugLen)
+	}
+	if postSlug == "" {
+		postSlug = fmt.Sprintf("post-%d", item.PostID)
+	}
+
+	blog, err := imp.findBlog(author, postSlug)
+	if err != nil {
+		return err
+	}
+	if blog != nil {
+		imp.report.postsSkipped++
+	} else {
+		blog = &data.Blog{
+			Title:     truncate(strings.TrimSpace(item.Title), maxTitleLength),
+			Content:   htmlToMarkdown(item.Content),
+			CreatedAt: createdAt,
+			UserID:    author.ID,
+			Slug:      postSlug,
+			Locale:    "en",
+		}
+		v := validator.New()
+		if data.ValidateBlog(v, blog); !v.IsValid() {
+			imp.skipInvalid(fmt.Sprintf("post %d %q", item.PostID, item.Title), v)
+			return nil
+		}
+		imp.report.postsCreated++
+		if !imp.dryRun {
+			err = imp.models.BlogModel.Insert(blog)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, category := range item.Categories {
+		if category.Domain != "post_tag" {
+			continue
+		}
+		err := imp.importTag(blog, category)
+		if err != nil {
+			return err
+		}
+	}
+	for _, comment := range item.Comments {
+		err := imp.importComment(blog, comment)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findBlog returns the already imported blog for slug, or nil if there is none.
+func (imp *importer) findBlog(author *data.User, postSlug string) (*data.Blog, error) {
+	if author.ID == 0 {
+		// The author is only created in a dry run, so none of their posts exist yet.
+		return nil, nil
+	}
+	blog, err := imp.models.BlogModel.GetBySlug(author.ID, postSlug)
+	if err != nil {
+		if errors.Is(err, data.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return blog, nil
+}
+
+func (imp *importer) importTag(blog *data.Blog, category wxrCategory) error {
+	tag := &data.Tag{
+		Name: truncate(strings.TrimSpace(category.Name), maxTagNameLength),
+		Slug: truncate(slug.Make(category.Nicename), maxTagSlugLen),
+	}
+	if tag.Slug == "" {
+		tag.Slug = truncate(slug.Make(tag.Name), maxTagSlugLen)
+	}
+	if tag.Name == "" || tag.Slug == "" {
+		v := validator.New()
+		v.AddErrorMessage("tag", "must have a name that can be made into a slug")
+		imp.skipInvalid(fmt.Sprintf("tag %q", category.Name), v)
+		return nil
+	}
+	imp.report.tagsAttached++
+	if imp.dryRun {
+		return nil
+	}
+	err := imp.models.TagModel.Upsert(tag)
+	if err != nil {
+		return err
+	}
+	return imp.models.TagModel.AddToBlog(blog.ID, tag.ID)
+}
+
+func (imp *importer) importComment(blog *data.Blog, comment wxrComment) error {
+	if comment.Approved != "1" || (comment.Type != "" && comment.Type != "comment") {
+		return nil
+	}
+	createdAt, ok := parseWPDate(comment.Date)
+	if !ok {
+		createdAt = blog.CreatedAt
+	}
+	authorName := strings.TrimSpace(comment.Author)
+	if authorName == "" {
+		authorName = "Anonymous"
+	}
+
+	if blog.ID != 0 {
+		exists, err := imp.models.CommentModel.Exists(blog.ID, authorName, createdAt)
+		if err != nil {
+			return err
+		}
+		if exists {
+			imp.report.commentsSkipped++
+			return nil
+		}
+	}
+	imp.report.commentsCreated++
+	if imp.dryRun {
+		return nil
+	}
+
+	c := &data.Comment{
+		BlogID:     blog.ID,
+		AuthorName: authorName,
+		Content:    htmlToMarkdown(comment.Content),
+		CreatedAt:  createdAt,
+	}
+	if comment.AuthorEmail != "" {
+		user, err := imp.models.UserModel.GetByEmail(comment.AuthorEmail)
+		switch {
+		case err == nil:
+			c.UserID = &user.ID
+		case !errors.Is(err, data.ErrNoRows):
+			return err
+		}
+	}
+	return imp.models.CommentModel.Insert(c)
+}
+
+// skipInvalid records that what could not be imported, with the errors in v as the reason.
+func (imp *importer) skipInvalid(what string, v *validator.Validator) {
+	keys := make([]string, 0, len(v.Error))
+	for key := range v.Error {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	reasons := make([]string, 0, len(keys))
+	for _, key := range keys {
+		reasons = append(reasons, fmt.Sprintf("%s %v", key, v.Error[key]))
+	}
+	imp.report.invalid = append(imp.report.invalid, what+": "+strings.Join(reasons, ", "))
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return strings.TrimSpace(string([]rune(s)[:n]))
+}
+
+func (imp *importer) printReport() {
+	mode := "import"
+	if imp.dryRun {
+		mode = "dry run"
+	}
+	r := imp.report
+	fmt.Printf("WXR %s complete\n", mode)
+	fmt.Printf("  users:    %d created, %d already present\n", r.usersCreated, r.usersSkipped)
+	fmt.Printf("  posts:    %d created, %d already present\n", r.postsCreated, r.postsSkipped)
+	fmt.Printf("  tags:     %d attached\n", r.tagsAttached)
+	fmt.Printf("  comments: %d created, %d already present\n", r.commentsCreated, r.commentsSkipped)
+	fmt.Printf("  ignored:  %d drafts, pages and attachments\n", r.itemsIgnored)
+	fmt.Printf("  invalid:  %d skipped\n", len(r.invalid))
+	for _, line := range r.invalid {
+		fmt.Printf("    %s\n", line)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	tagRX        = regexp.MustCompile(`(?s)<!--.*?-->|<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*)>`)
+	attrRX       = regexp.MustCompile(`([a-zA-Z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	spacesRX     = regexp.MustCompile(`[ \t]+`)
+	blankLinesRX = regexp.MustCompile(`\n[ \t]*\n(\s*\n)+`)
+)
+
+// frame collects the output of an element whose content has to be post-processed
+// when it closes, such as link text or a quoted block.
+type frame struct {
+	tag  string
+	href string
+	buf  strings.Builder
+}
+
+type list struct {
+	ordered bool
+	index   int
+}
+
+// htmlToMarkdown converts the subset of HTML that WordPress produces into Markdown.
+// Unknown tags are dropped and their text kept. WordPress stores paragraphs as blank
+// lines rather than <p> tags, so newlines in text are preserved.
+func htmlToMarkdown(src string) string {
+	stack := []*frame{{}}
+	var lists []*list
+	inPre := false
+
+	out := func() *strings.Builder { return &stack[len(stack)-1].buf }
+	write := func(s string) { out().WriteString(s) }
+
+	text := func(s string) {
+		s = html.UnescapeString(s)
+		if !inPre {
+			s = spacesRX.ReplaceAllString(s, " ")
+		}
+		write(s)
+	}
+
+	last := 0
+	for _, m := range tagRX.FindAllStringSubmatchIndex(src, -1) {
+		text(src[last:m[0]])
+		last = m[1]
+		if m[2] < 0 {
+			// comment
+			continue
+		}
+		closing := src[m[2]:m[3]] == "/"
+		tag := strings.ToLower(src[m[4]:m[5]])
+		attrs := attributes(src[m[6]:m[7]])
+
+		if inPre && !(tag == "pre" && closing) {
+			continue
+		}
+
+		switch tag {
+		case "p", "div":
+			write("\n\n")
+		case "br":
+			write("  \n")
+		case "hr":
+			write("\n\n---\n\n")
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			if closing {
+				write("\n\n")
+			} else {
+				write("\n\n" + strings.Repeat("#", int(tag[1]-'0')) + " ")
+			}
+		case "strong", "b":
+			write("**")
+		case "em", "i":
+			write("_")
+		case "code":
+			write("`")
+		case "pre":
+			if closing {
+				inPre = false
+				write("\n```\n\n")
+			} else {
+				inPre = true
+				write("\n\n```\n")
+			}
+		case "img":
+			write("![" + attrs["alt"] + "](" + attrs["src"] + ")")
+		case "ul", "ol":
+			// Nested lists follow their item directly, so the list stays tight.
+			if closing {
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+				if len(lists) == 0 {
+					write("\n\n")
+				}
+			} else {
+				if len(lists) == 0 {
+					write("\n")
+				}
+				lists = append(lists, &list{ordered: tag == "ol"})
+			}
+		case "li":
+			if closing || len(lists) == 0 {
+				continue
+			}
+			l := lists[len(lists)-1]
+			l.index++
+			marker := "- "
+			if l.ordered {
+				marker = strconv.Itoa(l.index) + ". "
+			}
+			write("\n" + strings.Repeat("  ", len(lists)-1) + marker)
+		case "a", "blockquote":
+			if !closing {
+				stack = append(stack, &frame{tag: tag, href: attrs["href"]})
+				continue
+			}
+			if len(stack) == 1 || stack[len(stack)-1].tag != tag {
+				continue
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			inner := f.buf.String()
+			if tag == "a" {
+				if f.href == "" {
+					write(inner)
+				} else {
+					write("[" + strings.TrimSpace(inner) + "](" + f.href + ")")
+				}
+				continue
+			}
+			lines := strings.Split(strings.TrimSpace(inner), "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimRight("> "+strings.TrimSpace(line), " ")
+			}
+			write("\n\n" + strings.Join(lines, "\n") + "\n\n")
+		}
+	}
+	text(src[last:])
+
+	// Close anything left open by malformed markup.
+	for len(stack) > 1 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		write(f.buf.String())
+	}
+
+	result := blankLinesRX.ReplaceAllString(out().String(), "\n\n")
+	return strings.TrimSpace(result)
+}
+
+func attributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range attrRX.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(strings.Trim(m[2], `"'`))
+	}
+	return attrs
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"plain text", "Hello world", "Hello world"},
+		{"paragraphs", "<p>One</p><p>Two</p>", "One\n\nTwo"},
+		{"wordpress blank lines", "One\n\nTwo", "One\n\nTwo"},
+		{"line break", "One<br />Two", "One  \nTwo"},
+		{"emphasis", "<strong>bold</strong> and <em>italic</em>", "**bold** and _italic_"},
+		{"inline code", "run <code>go test</code>", "run `go test`"},
+		{"heading", "<h2>Title</h2>Text", "## Title\n\nText"},
+		{"link", `<a href="https://example.com">Example</a>`, "[Example](https://example.com)"},
+		{"link without href", "<a>Example</a>", "Example"},
+		{"image", `<img src="/a.png" alt="An image" />`, "![An image](/a.png)"},
+		{"unordered list", "<ul><li>a</li><li>b</li></ul>", "- a\n- b"},
+		{"ordered list", "<ol><li>a</li><li>b</li></ol>", "1. a\n2. b"},
+		{"nested list", "<ul><li>a<ul><li>b</li></ul></li><li>c</li></ul>", "- a\n  - b\n- c"},
+		{"text after list", "<ul><li>a</li></ul>after", "- a\n\nafter"},
+		{"blockquote", "<blockquote>Quoted\ntext</blockquote>", "> Quoted\n> text"},
+		{"preformatted", "<pre>a  <b>b</b>\n  c</pre>", "```\na  b\n  c\n```"},
+		{"entities", "Fish &amp; chips &lt;3", "Fish & chips <3"},
+		{"comments dropped", "a<!-- wp:paragraph -->b", "ab"},
+		{"unknown tags dropped", "<span class=\"x\">text</span>", "text"},
+		{"collapsed spaces", "a   \t b", "a b"},
+		{"unclosed link", `<a href="/x">text`, "text"},
+		{"horizontal rule", "a<hr />b", "a\n\n---\n\nb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := htmlToMarkdown(tt.html); got != tt.want {
+				t.Errorf("htmlToMarkdown(%q) = %q, want %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"too long", 3, "too"},
+		{"ab cd", 3, "ab"},
+		{"héllo", 2, "hé"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
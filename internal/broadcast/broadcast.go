@@ -0,0 +1,89 @@
+package broadcast
+
+import (
+	"sync"
+	"time"
+)
+
+type Event struct {
+	ID   int64
+	Type string
+	Data []byte
+}
+
+// Broker fans published events out to every subscriber and keeps a short history
+// so that reconnecting clients can resume from the last event they saw.
+type Broker struct {
+	mu          sync.Mutex
+	lastID      int64
+	history     []Event
+	historySize int
+	subscribers map[chan Event]struct{}
+	bufferSize  int
+}
+
+func New(historySize, bufferSize int) *Broker {
+	return &Broker{
+		// Seeding ids with the current time keeps them increasing across restarts,
+		// so a Last-Event-ID from a previous process never looks like a future event.
+		lastID:      time.Now().UnixNano(),
+		historySize: historySize,
+		subscribers: make(map[chan Event]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Publish records the event and sends it to all subscribers. Subscribers that
+// cannot keep up are dropped and have their channel closed.
+func (b *Broker) Publish(eventType string, data []byte) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{ID: b.lastID, Type: eventType, Data: data}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a new subscriber. Events newer than lastEventID that are still
+// in the history are returned for replay; a lastEventID of 0 replays nothing.
+// The returned function must be called to unsubscribe.
+func (b *Broker) Subscribe(lastEventID int64) (<-chan Event, []Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event
+	if lastEventID > 0 {
+		for _, event := range b.history {
+			if event.ID > lastEventID {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	ch := make(chan Event, b.bufferSize)
+	b.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, replay, unsubscribe
+}
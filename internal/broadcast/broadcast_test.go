@@ -0,0 +1,81 @@
+package broadcast
+
+import "testing"
+
+func TestSubscribeReplaysEventsAfterLastEventID(t *testing.T) {
+	b := New(3, 10)
+	var ids []int64
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		ids = append(ids, b.Publish("blog.created", []byte(name)).ID)
+	}
+
+	tests := []struct {
+		name        string
+		lastEventID int64
+		want        []string
+	}{
+		{"no last event id", 0, nil},
+		{"latest event", ids[4], nil},
+		{"within history", ids[2], []string{"d", "e"}},
+		{"older than history", ids[0], []string{"c", "d", "e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, replay, unsubscribe := b.Subscribe(tt.lastEventID)
+			defer unsubscribe()
+			if len(replay) != len(tt.want) {
+				t.Fatalf("got %d replayed events, want %d", len(replay), len(tt.want))
+			}
+			for i, event := range replay {
+				if string(event.Data) != tt.want[i] {
+					t.Errorf("replay[%d] = %q, want %q", i, event.Data, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPublishDeliversToSubscribers(t *testing.T) {
+	b := New(10, 10)
+	ch, _, unsubscribe := b.Subscribe(0)
+	defer unsubscribe()
+
+	first := b.Publish("blog.created", []byte("a"))
+	second := b.Publish("blog.updated", []byte("b"))
+	if second.ID <= first.ID {
+		t.Fatalf("event ids %d, %d are not increasing", first.ID, second.ID)
+	}
+	for _, want := range []Event{first, second} {
+		got := <-ch
+		if got.ID != want.ID || got.Type != want.Type {
+			t.Errorf("got event %d %q, want %d %q", got.ID, got.Type, want.ID, want.Type)
+		}
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := New(10, 1)
+	ch, _, unsubscribe := b.Subscribe(0)
+	defer unsubscribe()
+
+	b.Publish("blog.created", []byte("a"))
+	b.Publish("blog.created", []byte("b"))
+
+	if _, ok := <-ch; !ok {
+		t.Fatal("buffered event was lost")
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("channel of a subscriber that fell behind was not closed")
+	}
+}
+
+func TestUnsubscribeClosesChannelOnce(t *testing.T) {
+	b := New(10, 1)
+	ch, _, unsubscribe := b.Subscribe(0)
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel was not closed")
+	}
+	b.Publish("blog.created", []byte("a"))
+}
@@ -0,0 +1,217 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// DeletedUserEmail is the email of the suspended placeholder user that owns the posts
+// of deleted accounts which chose to keep them anonymously.
+const DeletedUserEmail = "deleted-user@goblog.invalid"
+
+// ErrNoDeletedUser is returned by Purge when the deleted user is missing, so posts
+// that are to be kept have no owner to move to.
+var ErrNoDeletedUser = errors.New("the " + DeletedUserEmail + " user does not exist")
+
+// DeletedUserName replaces the author name of comments left by deleted accounts.
+const DeletedUserName = "Deleted user"
+
+// AccountDeletion is a pending request to delete an account. The account is only
+// purged at PurgeAt, so the request can be cancelled until then.
+type AccountDeletion struct {
+	UserID      int       `json:"-"`
+	DeletePosts bool      `json:"delete_posts"`
+	RequestedAt time.Time `json:"requested_at"`
+	PurgeAt     time.Time `json:"purge_at"`
+}
+
+type AccountDeletionModel struct {
+	DB *sql.DB
+}
+
+// Insert schedules the deletion, replacing an earlier request of the same user.
+func (m AccountDeletionModel) Insert(d *AccountDeletion) error {
+	query := `
+	INSERT INTO account_deletions (user_id, delete_posts, purge_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO UPDATE
+	SET delete_posts = EXCLUDED.delete_posts, requested_at = NOW(), purge_at = EXCLUDED.purge_at
+	RETURNING requested_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, d.UserID, d.DeletePosts, d.PurgeAt).Scan(&d.RequestedAt)
+}
+
+func (m AccountDeletionModel) Get(userID int) (*AccountDeletion, error) {
+	query := `
+	SELECT user_id, delete_posts, requested_at, purge_at
+	FROM account_deletions WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var d AccountDeletion
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&d.UserID, &d.DeletePosts, &d.RequestedAt, &d.PurgeAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return &d, nil
+}
+
+// Delete cancels the pending deletion of userID.
+func (m AccountDeletionModel) Delete(userID int) error {
+	query := `DELETE FROM account_deletions WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// GetDue returns up to limit deletions whose grace period is over, oldest first.
+func (m AccountDeletionModel) GetDue(limit int) ([]AccountDeletion, error) {
+	query := `
+	SELECT user_id, delete_posts, requested_at, purge_at
+	FROM account_deletions
+	WHERE purge_at <= NOW()
+	ORDER BY purge_at, user_id
+	LIMIT $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deletions := []AccountDeletion{}
+	for rows.Next() {
+		var d AccountDeletion
+		err := rows.Scan(&d.UserID, &d.DeletePosts, &d.RequestedAt, &d.PurgeAt)
+		if err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return deletions, nil
+}
+
+// Postpone moves the purge of userID to until, so a deletion that failed to purge is
+// retried later without holding up the others.
+func (m AccountDeletionModel) Postpone(userID int, until time.Time) error {
+	query := `UPDATE account_deletions SET purge_at = $2 WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, until)
+	return err
+}
+
+// Purge deletes the account of d, which cascades to everything it owns. Unless the
+// user asked for their posts to be deleted, the posts are first moved to the deleted
+// user and the comments kept without the user's name.
+func (m AccountDeletionModel) Purge(d AccountDeletion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if d.DeletePosts {
+		_, err = tx.ExecContext(ctx, `DELETE FROM comments WHERE user_id = $1`, d.UserID)
+	} else {
+		var deletedUserID int
+		err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE email = $1`, DeletedUserEmail).Scan(&deletedUserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoDeletedUser
+			}
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE blogs SET user_id = $2 WHERE user_id = $1`, d.UserID, deletedUserID)
+		if err != nil {
+			return err
+		}
+		query := `UPDATE comments SET user_id = NULL, author_name = $2 WHERE user_id = $1`
+		_, err = tx.ExecContext(ctx, query, d.UserID, DeletedUserName)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, d.UserID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type DataExportModel struct {
+	DB *sql.DB
+}
+
+// Insert stores archive as the data export of userID until ttl passes, replacing an
+// earlier export.
+func (m DataExportModel) Insert(userID int, archive []byte, ttl time.Duration) error {
+	query := `
+	INSERT INTO data_exports (user_id, archive, expiry)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO UPDATE
+	SET archive = EXCLUDED.archive, created_at = NOW(), expiry = EXCLUDED.expiry`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, archive, time.Now().Add(ttl))
+	return err
+}
+
+// Get returns the archive of the unexpired data export of userID.
+func (m DataExportModel) Get(userID int) ([]byte, error) {
+	query := `SELECT archive FROM data_exports WHERE user_id = $1 AND expiry > NOW()`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var archive []byte
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&archive)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return archive, nil
+}
+
+func (m DataExportModel) DeleteExpired() error {
+	query := `DELETE FROM data_exports WHERE expiry <= NOW()`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query)
+	return err
+}
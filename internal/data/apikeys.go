@@ -0,0 +1,192 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+// APIKeyPrefix starts every API key, which tells them apart from session tokens.
+const APIKeyPrefix = "gbk_"
+
+// APIKey is a long-lived credential for automation. It can only be used on routes
+// guarded by one of its scopes.
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Plaintext  string     `json:"key,omitempty"`
+	Hash       []byte     `json:"-"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Expiry     *time.Time `json:"expiry"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// IsAPIKey reports whether a bearer token looks like an API key rather than a token.
+func IsAPIKey(plaintext string) bool {
+	return strings.HasPrefix(plaintext, APIKeyPrefix)
+}
+
+// NewAPIKey returns a key for userID with a random secret. It is not stored yet.
+func NewAPIKey(userID int, name string, scopes []string, expiry *time.Time) (*APIKey, error) {
+	randomBytes := make([]byte, 20)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := APIKeyPrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes))
+	hash := sha256.Sum256([]byte(plaintext))
+	return &APIKey{
+		UserID:    userID,
+		Name:      name,
+		Plaintext: plaintext,
+		Hash:      hash[:],
+		Prefix:    plaintext[:len(APIKeyPrefix)+6],
+		Scopes:    scopes,
+		Expiry:    expiry,
+	}, nil
+}
+
+// ValidateAPIKey checks key against the permissions its owner currently holds, so a
+// key can never be granted more than its owner.
+func ValidateAPIKey(v *validator.Validator, key *APIKey, granted Permissions) {
+	v.Check(strings.TrimSpace(key.Name) != "", "name", "must be provided")
+	v.Check(len(key.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(len(key.Scopes) > 0, "scopes", "must contain at least one permission")
+	seen := make(map[string]bool)
+	for _, scope := range key.Scopes {
+		v.Check(validator.In(scope, PermissionCodes...), "scopes", "contains an unknown permission")
+		v.Check(granted.Include(scope), "scopes", "must only contain permissions you hold")
+		v.Check(!seen[scope], "scopes", "must not contain duplicate values")
+		seen[scope] = true
+	}
+	if key.Expiry != nil {
+		v.Check(key.Expiry.After(time.Now()), "expiry", "must be in the future")
+	}
+}
+
+func ValidateAPIKeyPlaintext(v *validator.Validator, plaintext string) {
+	v.Check(IsAPIKey(plaintext), "key", "must start with "+APIKeyPrefix)
+	v.Check(len(plaintext) == len(APIKeyPrefix)+32, "key", "must be 36 bytes long")
+}
+
+type APIKeyModel struct {
+	DB *sql.DB
+}
+
+func (m APIKeyModel) Insert(key *APIKey) error {
+	query := `
+	INSERT INTO api_keys (user_id, name, hash, prefix, scopes, expiry)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	args := []interface{}{key.UserID, key.Name, key.Hash, key.Prefix, pq.Array(key.Scopes), key.Expiry}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&key.ID, &key.CreatedAt)
+}
+
+// GetForPlaintext returns the unexpired key matching plaintext. Like Touch on tokens,
+// it records the use at most once a minute.
+func (m APIKeyModel) GetForPlaintext(plaintext string) (*APIKey, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+	query := `
+	SELECT id, user_id, name, prefix, scopes, expiry, created_at, last_used_at
+	FROM api_keys
+	WHERE hash = $1 AND (expiry IS NULL OR expiry > NOW())`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var key APIKey
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(
+		&key.ID, &key.UserID, &key.Name, &key.Prefix, pq.Array(&key.Scopes),
+		&key.Expiry, &key.CreatedAt, &key.LastUsedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+
+	query = `
+	UPDATE api_keys SET last_used_at = NOW()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+	_, err = m.DB.ExecContext(ctx, query, key.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (m APIKeyModel) GetAllForUser(userID int) ([]*APIKey, error) {
+	query := `
+	SELECT id, user_id, name, prefix, scopes, expiry, created_at, last_used_at
+	FROM api_keys
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		var key APIKey
+		err := rows.Scan(
+			&key.ID, &key.UserID, &key.Name, &key.Prefix, pq.Array(&key.Scopes),
+			&key.Expiry, &key.CreatedAt, &key.LastUsedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Delete revokes the key id of userID. It returns ErrNoRows if there is no such key.
+func (m APIKeyModel) Delete(userID, id int) error {
+	query := `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+func (m APIKeyModel) DeleteAllForUser(userID int) error {
+	query := `DELETE FROM api_keys WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, userID)
+	return err
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditUserActivated     = "user.activated"
+	AuditUserDeactivated   = "user.deactivated"
+	AuditUserPasswordReset = "user.password_reset"
+	AuditUserTokensRevoked = "user.tokens_revoked"
+	AuditUserDeleted       = "user.deleted"
+	AuditUserRolesUpdated  = "user.roles_updated"
+	AuditUserUnlocked      = "user.unlocked"
+	AuditUserPurged        = "user.purged"
+)
+
+type AuditLog struct {
+	ID           int                    `json:"id"`
+	ActorID      *int                   `json:"actor_id"`
+	Action       string                 `json:"action"`
+	TargetUserID *int                   `json:"target_user_id,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+type AuditLogModel struct {
+	DB *sql.DB
+}
+
+func (m AuditLogModel) Insert(entry *AuditLog) error {
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return err
+	}
+	if entry.Details == nil {
+		details = []byte("{}")
+	}
+	query := `
+	INSERT INTO audit_logs (actor_id, action, target_user_id, details)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+	args := []interface{}{entry.ActorID, entry.Action, entry.TargetUserID, details}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// GetAll returns audit log entries newest first. A targetUserID of zero returns
+// entries for every user.
+func (m AuditLogModel) GetAll(targetUserID int, filters Filters) ([]AuditLog, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), id, actor_id, action, target_user_id, details, created_at
+	FROM audit_logs
+	WHERE ($1 = 0 OR target_user_id = $1)
+	ORDER BY id DESC
+	LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, targetUserID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := []AuditLog{}
+	for rows.Next() {
+		var entry AuditLog
+		var details []byte
+		err := rows.Scan(&totalRecords, &entry.ID, &entry.ActorID, &entry.Action, &entry.TargetUserID, &details, &entry.CreatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		err = json.Unmarshal(details, &entry.Details)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	return entries, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
@@ -14,8 +14,13 @@ type Blog struct {
 	Title     string    `json:"title"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 	UserID    int       `json:"-"`
 	Slug      string    `json:"slug"`
+	Locale    string    `json:"locale,omitempty"`
+	Hidden    bool      `json:"hidden,omitempty"`
+
+	Translations []TranslationRef `json:"translations,omitempty"`
 }
 
 func ValidateBlog(v *validator.Validator, blog *Blog) {
@@ -23,6 +28,7 @@ func ValidateBlog(v *validator.Validator, blog *Blog) {
 	v.Check(len(blog.Title) >= 2, "title", "must be greater than 2 characters")
 	v.Check(blog.Content != "", "content", "must be provided")
 	v.Check(len(blog.Content) >= 5, "content", "must be greater than 5 characters")
+	ValidateLocale(v, blog.Locale)
 
 }
 
@@ -32,13 +38,13 @@ type BlogModel struct {
 
 func (m BlogModel) Insert(b *Blog) error {
 	query := `
-	INSERT INTO blogs (title, content, created_at, user_id, slug)
-	VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	args := []interface{}{b.Title, b.Content, b.CreatedAt, b.UserID, b.Slug}
+	INSERT INTO blogs (title, content, created_at, updated_at, user_id, slug, locale)
+	VALUES ($1, $2, $3, $3, $4, $5, $6) RETURNING id, updated_at`
+	args := []interface{}{b.Title, b.Content, b.CreatedAt, b.UserID, b.Slug, b.Locale}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&b.ID)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&b.ID, &b.UpdatedAt)
 
 	if err != nil {
 		return err
@@ -48,7 +54,8 @@ func (m BlogModel) Insert(b *Blog) error {
 
 func (m BlogModel) List() ([]Blog, error) {
 	query := `
-	SELECT id, title, content, created_at, slug FROM blogs`
+	SELECT id, title, content, created_at, updated_at, user_id, slug, locale FROM blogs
+	WHERE hidden = false`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -61,7 +68,71 @@ func (m BlogModel) List() ([]Blog, error) {
 
 	for rows.Next() {
 		var b Blog
-		err := rows.Scan(&b.ID, &b.Title, &b.Content, &b.CreatedAt, &b.Slug)
+		err := rows.Scan(&b.ID, &b.Title, &b.Content, &b.CreatedAt, &b.UpdatedAt, &b.UserID, &b.Slug, &b.Locale)
+		if err != nil {
+			return nil, err
+		}
+		blogs = append(blogs, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return blogs, nil
+}
+
+// GetAllForUser returns a page of the posts of userID that aren't hidden, newest first.
+func (m BlogModel) GetAllForUser(userID int, filters Filters) ([]Blog, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), id, title, content, created_at, updated_at, user_id, slug, locale
+	FROM blogs
+	WHERE user_id = $1 AND hidden = false
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	blogs := []Blog{}
+	for rows.Next() {
+		var b Blog
+		err := rows.Scan(&totalRecords, &b.ID, &b.Title, &b.Content, &b.CreatedAt, &b.UpdatedAt, &b.UserID, &b.Slug, &b.Locale)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		blogs = append(blogs, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	return blogs, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// GetAllOwnedBy returns every post of userID, including hidden ones, oldest first.
+func (m BlogModel) GetAllOwnedBy(userID int) ([]Blog, error) {
+	query := `
+	SELECT id, title, content, created_at, updated_at, user_id, slug, locale, hidden
+	FROM blogs
+	WHERE user_id = $1
+	ORDER BY created_at, id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blogs := []Blog{}
+	for rows.Next() {
+		var b Blog
+		err := rows.Scan(&b.ID, &b.Title, &b.Content, &b.CreatedAt, &b.UpdatedAt, &b.UserID, &b.Slug, &b.Locale, &b.Hidden)
 		if err != nil {
 			return nil, err
 		}
@@ -74,11 +145,31 @@ func (m BlogModel) List() ([]Blog, error) {
 }
 
 func (m BlogModel) Get(id int) (*Blog, error) {
-	query := `SELECT id, title, content, created_at, user_id, slug FROM blogs WHERE id = $1`
+	query := `SELECT id, title, content, created_at, updated_at, user_id, slug, locale, hidden FROM blogs WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var blog Blog
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&blog.ID, &blog.Title, &blog.Content, &blog.CreatedAt, &blog.UpdatedAt, &blog.UserID, &blog.Slug, &blog.Locale, &blog.Hidden)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return &blog, nil
+}
+
+func (m BlogModel) GetBySlug(userID int, slug string) (*Blog, error) {
+	query := `
+	SELECT id, title, content, created_at, updated_at, user_id, slug, locale FROM blogs
+	WHERE user_id = $1 AND slug = $2
+	ORDER BY id LIMIT 1`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	var blog Blog
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(&blog.ID, &blog.Title, &blog.Content, &blog.CreatedAt, &blog.UserID, &blog.Slug)
+	err := m.DB.QueryRowContext(ctx, query, userID, slug).Scan(&blog.ID, &blog.Title, &blog.Content, &blog.CreatedAt, &blog.UpdatedAt, &blog.UserID, &blog.Slug, &blog.Locale)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -112,16 +203,26 @@ func (m BlogModel) Update(b *Blog) (*Blog, error) {
 	UPDATE blogs SET
 	title = $1, 
 	content = $2,
-	slug = $3 
+	slug = $3,
+	updated_at = NOW()
 	WHERE id = $4 
-	RETURNING id, title, content, slug`
+	RETURNING id, title, content, slug, locale, updated_at`
 	args := []interface{}{b.Title, b.Content, b.Slug, b.ID}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&b.ID, &b.Title, &b.Content, &b.Slug)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&b.ID, &b.Title, &b.Content, &b.Slug, &b.Locale, &b.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return b, err
 }
+
+func (m BlogModel) SetHidden(id int, hidden bool) error {
+	query := `UPDATE blogs SET hidden = $1, updated_at = NOW() WHERE id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, hidden, id)
+	return err
+}
@@ -0,0 +1,79 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type BookmarkModel struct {
+	DB *sql.DB
+}
+
+func (m BookmarkModel) Insert(userID, blogID int) error {
+	query := `
+	INSERT INTO bookmarks (user_id, blog_id)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, blogID)
+	return err
+}
+
+func (m BookmarkModel) Delete(userID, blogID int) (int64, error) {
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND blog_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, blogID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m BookmarkModel) Exists(userID, blogID int) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM bookmarks WHERE user_id = $1 AND blog_id = $2)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, query, userID, blogID).Scan(&exists)
+	return exists, err
+}
+
+// GetAllForUser returns the bookmarked blogs of userID, most recently bookmarked first.
+func (m BookmarkModel) GetAllForUser(userID int, filters Filters) ([]Blog, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), blogs.id, blogs.title, blogs.content, blogs.created_at, blogs.user_id, blogs.slug
+	FROM bookmarks
+	INNER JOIN blogs ON blogs.id = bookmarks.blog_id
+	WHERE bookmarks.user_id = $1 AND blogs.hidden = false
+	ORDER BY bookmarks.created_at DESC, blogs.id DESC
+	LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	blogs := []Blog{}
+	for rows.Next() {
+		var b Blog
+		err := rows.Scan(&totalRecords, &b.ID, &b.Title, &b.Content, &b.CreatedAt, &b.UserID, &b.Slug)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		blogs = append(blogs, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	return blogs, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+// Comment is a reader comment on a blog. UserID is nil for comments left by
+// people without an account, such as those imported from other platforms.
+type Comment struct {
+	ID         int       `json:"id"`
+	BlogID     int       `json:"blog_id"`
+	UserID     *int      `json:"user_id,omitempty"`
+	AuthorName string    `json:"author_name"`
+	Content    string    `json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func ValidateComment(v *validator.Validator, c *Comment) {
+	v.Check(c.Content != "", "content", "must be provided")
+	v.Check(utf8.RuneCountInString(c.Content) <= 5000, "content", "must not be more than 5000 characters long")
+}
+
+type CommentModel struct {
+	DB *sql.DB
+}
+
+func (m CommentModel) Insert(c *Comment) error {
+	query := `
+	INSERT INTO comments (blog_id, user_id, author_name, content, created_at)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	args := []interface{}{c.BlogID, c.UserID, c.AuthorName, c.Content, c.CreatedAt}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&c.ID)
+}
+
+// Exists reports whether the blog already has a comment by authorName at createdAt.
+func (m CommentModel) Exists(blogID int, authorName string, createdAt time.Time) (bool, error) {
+	query := `
+	SELECT EXISTS(
+		SELECT 1 FROM comments
+		WHERE blog_id = $1 AND author_name = $2 AND created_at = $3
+	)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, query, blogID, authorName, createdAt).Scan(&exists)
+	return exists, err
+}
+
+func (m CommentModel) GetAllForBlog(blogID int) ([]Comment, error) {
+	query := `
+	SELECT id, blog_id, user_id, author_name, content, created_at
+	FROM comments
+	WHERE blog_id = $1
+	ORDER BY created_at, id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, blogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(&c.ID, &c.BlogID, &c.UserID, &c.AuthorName, &c.Content, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// GetAllForUser returns the comments userID left on any blog, oldest first.
+func (m CommentModel) GetAllForUser(userID int) ([]Comment, error) {
+	query := `
+	SELECT id, blog_id, user_id, author_name, content, created_at
+	FROM comments
+	WHERE user_id = $1
+	ORDER BY created_at, id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(&c.ID, &c.BlogID, &c.UserID, &c.AuthorName, &c.Content, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
@@ -0,0 +1,150 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	DigestSending = "sending"
+	DigestSent    = "sent"
+	DigestSkipped = "skipped"
+	DigestFailed  = "failed"
+)
+
+type DigestModel struct {
+	DB *sql.DB
+}
+
+// GetRecipientsDue returns activated users with digests enabled who have not yet
+// been claimed for the period starting at periodStart. Users whose digest failed
+// before retryBefore are returned again, so they are retried on a later run.
+func (m DigestModel) GetRecipientsDue(periodStart, retryBefore time.Time, limit int) ([]User, error) {
+	query := `
+	SELECT id, email, activated FROM users
+	WHERE activated = true AND digest_enabled = true
+	AND NOT EXISTS (
+		SELECT 1 FROM digest_sends
+		WHERE digest_sends.user_id = users.id AND digest_sends.period_start = $1
+		AND NOT (digest_sends.status = $2 AND digest_sends.sent_at < $3)
+	)
+	ORDER BY id
+	LIMIT $4`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, periodStart, DigestFailed, retryBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&user.ID, &user.Email, &user.Activated)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Claim records that the digest for userID and periodStart is being sent. It returns
+// false when the digest was already claimed, so a restarted job never sends it twice.
+// A digest that failed can be claimed again.
+func (m DigestModel) Claim(userID int, periodStart time.Time) (bool, error) {
+	query := `
+	INSERT INTO digest_sends (user_id, period_start, status)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, period_start) DO UPDATE
+	SET status = EXCLUDED.status, sent_at = NULL
+	WHERE digest_sends.status = $4`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, periodStart, DigestSending, DigestFailed)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+func (m DigestModel) Finish(userID int, periodStart time.Time, status string) error {
+	query := `
+	UPDATE digest_sends SET status = $1, sent_at = NOW()
+	WHERE user_id = $2 AND period_start = $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, status, userID, periodStart)
+	return err
+}
+
+// GetFollowedPosts returns posts by authors userID follows created in [from, to).
+func (m DigestModel) GetFollowedPosts(userID int, from, to time.Time, limit int) ([]Blog, error) {
+	query := `
+	SELECT blogs.id, blogs.title, blogs.content, blogs.created_at, blogs.user_id, blogs.slug
+	FROM blogs
+	INNER JOIN follows ON follows.followed_id = blogs.user_id
+	WHERE follows.follower_id = $1 AND blogs.hidden = false AND blogs.created_at >= $2 AND blogs.created_at < $3
+	ORDER BY blogs.created_at DESC
+	LIMIT $4`
+	return m.queryBlogs(query, userID, from, to, limit)
+}
+
+// GetPopularPosts returns the most bookmarked posts created in [from, to).
+func (m DigestModel) GetPopularPosts(from, to time.Time, limit int) ([]Blog, error) {
+	query := `
+	SELECT blogs.id, blogs.title, blogs.content, blogs.created_at, blogs.user_id, blogs.slug
+	FROM blogs
+	LEFT JOIN bookmarks ON bookmarks.blog_id = blogs.id
+	WHERE blogs.hidden = false AND blogs.created_at >= $1 AND blogs.created_at < $2
+	GROUP BY blogs.id
+	ORDER BY count(bookmarks.user_id) DESC, blogs.created_at DESC
+	LIMIT $3`
+	return m.queryBlogs(query, from, to, limit)
+}
+
+func (m DigestModel) queryBlogs(query string, args ...interface{}) ([]Blog, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blogs []Blog
+	for rows.Next() {
+		var b Blog
+		err := rows.Scan(&b.ID, &b.Title, &b.Content, &b.CreatedAt, &b.UserID, &b.Slug)
+		if err != nil {
+			return nil, err
+		}
+		blogs = append(blogs, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return blogs, nil
+}
+
+func (m DigestModel) SetEnabled(userID int, enabled bool) error {
+	query := `UPDATE users SET digest_enabled = $1 WHERE id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, enabled, userID)
+	return err
+}
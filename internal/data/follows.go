@@ -0,0 +1,153 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type FollowCounts struct {
+	Followers int `json:"followers"`
+	Following int `json:"following"`
+}
+
+type FollowModel struct {
+	DB *sql.DB
+}
+
+func (m FollowModel) Insert(followerID, followedID int) error {
+	query := `
+	INSERT INTO follows (follower_id, followed_id)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, followerID, followedID)
+	return err
+}
+
+func (m FollowModel) Delete(followerID, followedID int) (int64, error) {
+	query := `DELETE FROM follows WHERE follower_id = $1 AND followed_id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, followerID, followedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m FollowModel) Exists(followerID, followedID int) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM follows WHERE follower_id = $1 AND followed_id = $2)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, query, followerID, followedID).Scan(&exists)
+	return exists, err
+}
+
+func (m FollowModel) Counts(userID int) (*FollowCounts, error) {
+	query := `
+	SELECT
+	(SELECT count(*) FROM follows WHERE followed_id = $1),
+	(SELECT count(*) FROM follows WHERE follower_id = $1)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var counts FollowCounts
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&counts.Followers, &counts.Following)
+	if err != nil {
+		return nil, err
+	}
+	return &counts, nil
+}
+
+// Cursor marks a position in a reverse-chronological list of blogs.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+func (c Cursor) Encode() string {
+	value := fmt.Sprintf("%d:%d", c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	value, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.Split(string(value), ":")
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: time.Unix(0, nanos), ID: id}, nil
+}
+
+// Feed returns up to limit blogs by authors userID follows, newest first, starting after cursor.
+// The returned cursor is nil when there are no more blogs.
+func (m FollowModel) Feed(userID int, cursor *Cursor, limit int) ([]Blog, *Cursor, error) {
+	query := `
+	SELECT blogs.id, blogs.title, blogs.content, blogs.created_at, blogs.user_id, blogs.slug
+	FROM blogs
+	INNER JOIN follows ON follows.followed_id = blogs.user_id
+	WHERE follows.follower_id = $1 AND blogs.hidden = false
+	AND ($2::timestamptz IS NULL OR (blogs.created_at, blogs.id) < ($2, $3))
+	ORDER BY blogs.created_at DESC, blogs.id DESC
+	LIMIT $4`
+
+	var after interface{}
+	var afterID int
+	if cursor != nil {
+		after = cursor.CreatedAt
+		afterID = cursor.ID
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, after, afterID, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	blogs := []Blog{}
+	for rows.Next() {
+		var b Blog
+		err := rows.Scan(&b.ID, &b.Title, &b.Content, &b.CreatedAt, &b.UserID, &b.Slug)
+		if err != nil {
+			return nil, nil, err
+		}
+		blogs = append(blogs, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var next *Cursor
+	if len(blogs) > limit {
+		blogs = blogs[:limit]
+		last := blogs[len(blogs)-1]
+		next = &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	return blogs, next, nil
+}
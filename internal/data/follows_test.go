@@ -0,0 +1,48 @@
+package data
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC), ID: 42},
+		{CreatedAt: time.Unix(0, 0), ID: 1},
+		{CreatedAt: time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC), ID: 7},
+	}
+	for _, want := range tests {
+		got, err := DecodeCursor(want.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor(%v) returned error %v", want, err)
+		}
+		if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsInvalidInput(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"not base64", "!!!"},
+		{"missing id", encode("1700000000")},
+		{"too many parts", encode("1:2:3")},
+		{"non numeric time", encode("abc:2")},
+		{"non numeric id", encode("1:abc")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeCursor(tt.input)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("got err %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// OIDCState is what the login step remembers for the callback of a sign in with an
+// external identity provider.
+type OIDCState struct {
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+}
+
+type OIDCStateModel struct {
+	DB *sql.DB
+}
+
+func (m OIDCStateModel) Insert(state string, s *OIDCState, ttl time.Duration) error {
+	hash := sha256.Sum256([]byte(state))
+	query := `
+	INSERT INTO oidc_states (hash, provider, code_verifier, nonce, expiry)
+	VALUES ($1, $2, $3, $4, $5)`
+	args := []interface{}{hash[:], s.Provider, s.CodeVerifier, s.Nonce, time.Now().Add(ttl)}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Take deletes and returns the unexpired state, so each one can only be used once.
+// Expired states are cleared out at the same time.
+func (m OIDCStateModel) Take(state string) (*OIDCState, error) {
+	hash := sha256.Sum256([]byte(state))
+	query := `
+	DELETE FROM oidc_states WHERE hash = $1 OR expiry < NOW()
+	RETURNING provider, code_verifier, nonce, hash = $1 AND expiry >= NOW()`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found *OIDCState
+	for rows.Next() {
+		var s OIDCState
+		var match bool
+		err := rows.Scan(&s.Provider, &s.CodeVerifier, &s.Nonce, &match)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			found = &s
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrNoRows
+	}
+	return found, nil
+}
+
+type IdentityModel struct {
+	DB *sql.DB
+}
+
+// GetUser returns the user linked to subject at provider.
+func (m IdentityModel) GetUser(provider, subject string) (*User, error) {
+	query := `
+	SELECT users.id, users.email, users.password, users.activated, users.suspended
+	FROM users
+	INNER JOIN user_identities ON user_identities.user_id = users.id
+	WHERE user_identities.provider = $1 AND user_identities.subject = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var user User
+	err := m.DB.QueryRowContext(ctx, query, provider, subject).Scan(&user.ID, &user.Email, &user.Password.hash, &user.Activated, &user.Suspended)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+func (m IdentityModel) Insert(provider, subject string, userID int, email string) error {
+	query := `
+	INSERT INTO user_identities (provider, subject, user_id, email)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT DO NOTHING`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, provider, subject, userID, email)
+	return err
+}
+
+// PendingIdentity is an identity waiting for a second factor before it is linked to
+// an existing user.
+type PendingIdentity struct {
+	Provider string
+	Subject  string
+	UserID   int
+	Email    string
+}
+
+func (m IdentityModel) InsertPending(token string, p *PendingIdentity, ttl time.Duration) error {
+	hash := sha256.Sum256([]byte(token))
+	query := `
+	INSERT INTO pending_identities (hash, provider, subject, user_id, email, expiry)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+	args := []interface{}{hash[:], p.Provider, p.Subject, p.UserID, p.Email, time.Now().Add(ttl)}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// TakePending deletes and returns the unexpired pending identity of token, so each
+// token allows a single attempt. Expired ones are cleared out at the same time.
+func (m IdentityModel) TakePending(token string) (*PendingIdentity, error) {
+	hash := sha256.Sum256([]byte(token))
+	query := `
+	DELETE FROM pending_identities WHERE hash = $1 OR expiry < NOW()
+	RETURNING provider, subject, user_id, email, hash = $1 AND expiry >= NOW()`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found *PendingIdentity
+	for rows.Next() {
+		var p PendingIdentity
+		var match bool
+		err := rows.Scan(&p.Provider, &p.Subject, &p.UserID, &p.Email, &match)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			found = &p
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrNoRows
+	}
+	return found, nil
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Failed logins are counted both per email and per client IP, so guessing passwords
+// is limited whether an attacker targets one account or rotates through many.
+const (
+	LoginFailureEmail = "email"
+	LoginFailureIP    = "ip"
+)
+
+type LoginFailure struct {
+	Kind          string     `json:"kind"`
+	Subject       string     `json:"subject"`
+	Failures      int        `json:"failures"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+}
+
+type LoginFailureModel struct {
+	DB *sql.DB
+}
+
+func (m LoginFailureModel) Get(kind, subject string) (*LoginFailure, error) {
+	query := `
+	SELECT kind, subject, failures, locked_until, last_failure_at
+	FROM login_failures
+	WHERE kind = $1 AND subject = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var failure LoginFailure
+	err := m.DB.QueryRowContext(ctx, query, kind, subject).Scan(
+		&failure.Kind, &failure.Subject, &failure.Failures, &failure.LockedUntil, &failure.LastFailureAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return &failure, nil
+}
+
+// Record counts a failed login and returns the new count. Failures older than window
+// are forgotten, and so are stale rows of other subjects.
+func (m LoginFailureModel) Record(kind, subject string, window time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	cutoff := time.Now().Add(-window)
+	query := `
+	DELETE FROM login_failures
+	WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < NOW())`
+	_, err := m.DB.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `
+	INSERT INTO login_failures (kind, subject, failures)
+	VALUES ($1, $2, 1)
+	ON CONFLICT (kind, subject) DO UPDATE
+	SET failures = CASE WHEN login_failures.last_failure_at < $3 THEN 1 ELSE login_failures.failures + 1 END,
+	last_failure_at = NOW()
+	RETURNING failures`
+	var failures int
+	err = m.DB.QueryRowContext(ctx, query, kind, subject, cutoff).Scan(&failures)
+	return failures, err
+}
+
+// Lock refuses logins for subject until the given time.
+func (m LoginFailureModel) Lock(kind, subject string, until time.Time) error {
+	query := `UPDATE login_failures SET locked_until = $3 WHERE kind = $1 AND subject = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, kind, subject, until)
+	return err
+}
+
+// Delete forgets the failures of subject, which also lifts any lockout.
+func (m LoginFailureModel) Delete(kind, subject string) error {
+	query := `DELETE FROM login_failures WHERE kind = $1 AND subject = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, kind, subject)
+	return err
+}
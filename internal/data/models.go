@@ -6,6 +6,33 @@ type Models struct {
 	UserModel  UserModel
 	TokenModel TokenModel
 	BlogModel  BlogModel
+
+	WebhookModel         WebhookModel
+	WebhookDeliveryModel WebhookDeliveryModel
+	BookmarkModel        BookmarkModel
+	FollowModel          FollowModel
+	NotificationModel    NotificationModel
+	DigestModel          DigestModel
+	SubscriberModel      SubscriberModel
+	TranslationModel     TranslationModel
+	TagModel             TagModel
+	CommentModel         CommentModel
+
+	ReportModel           ReportModel
+	ModerationActionModel ModerationActionModel
+	PermissionModel       PermissionModel
+	RoleModel             RoleModel
+	AuditLogModel         AuditLogModel
+	TOTPModel             TOTPModel
+	RecoveryCodeModel     RecoveryCodeModel
+	OIDCStateModel        OIDCStateModel
+	IdentityModel         IdentityModel
+	APIKeyModel           APIKeyModel
+	RevocationModel       RevocationModel
+	LoginFailureModel     LoginFailureModel
+	ProfileModel          ProfileModel
+	AccountDeletionModel  AccountDeletionModel
+	DataExportModel       DataExportModel
 }
 
 func NewModels(db *sql.DB) Models {
@@ -13,5 +40,32 @@ func NewModels(db *sql.DB) Models {
 		UserModel:  UserModel{DB: db},
 		TokenModel: TokenModel{DB: db},
 		BlogModel:  BlogModel{DB: db},
+
+		WebhookModel:         WebhookModel{DB: db},
+		WebhookDeliveryModel: WebhookDeliveryModel{DB: db},
+		BookmarkModel:        BookmarkModel{DB: db},
+		FollowModel:          FollowModel{DB: db},
+		NotificationModel:    NotificationModel{DB: db},
+		DigestModel:          DigestModel{DB: db},
+		SubscriberModel:      SubscriberModel{DB: db},
+		TranslationModel:     TranslationModel{DB: db},
+		TagModel:             TagModel{DB: db},
+		CommentModel:         CommentModel{DB: db},
+
+		ReportModel:           ReportModel{DB: db},
+		ModerationActionModel: ModerationActionModel{DB: db},
+		PermissionModel:       PermissionModel{DB: db},
+		RoleModel:             RoleModel{DB: db},
+		AuditLogModel:         AuditLogModel{DB: db},
+		TOTPModel:             TOTPModel{DB: db},
+		RecoveryCodeModel:     RecoveryCodeModel{DB: db},
+		OIDCStateModel:        OIDCStateModel{DB: db},
+		IdentityModel:         IdentityModel{DB: db},
+		APIKeyModel:           APIKeyModel{DB: db},
+		RevocationModel:       RevocationModel{DB: db},
+		LoginFailureModel:     LoginFailureModel{DB: db},
+		ProfileModel:          ProfileModel{DB: db},
+		AccountDeletionModel:  AccountDeletionModel{DB: db},
+		DataExportModel:       DataExportModel{DB: db},
 	}
 }
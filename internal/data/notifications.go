@@ -0,0 +1,164 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	NotificationComment = "comment"
+	NotificationFollow  = "follow"
+	NotificationMention = "mention"
+	NotificationReport  = "report"
+)
+
+var NotificationKinds = []string{NotificationComment, NotificationFollow, NotificationMention, NotificationReport}
+
+type Notification struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	ActorID   *int      `json:"actor_id,omitempty"`
+	Kind      string    `json:"kind"`
+	BlogID    *int      `json:"blog_id,omitempty"`
+	Message   string    `json:"message"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type NotificationModel struct {
+	DB *sql.DB
+}
+
+// Insert stores the notification unless the recipient has disabled its kind.
+// It reports whether a notification was created.
+func (m NotificationModel) Insert(n *Notification) (bool, error) {
+	query := `
+	INSERT INTO notifications (user_id, actor_id, kind, blog_id, message)
+	SELECT $1, $2, $3, $4, $5
+	WHERE NOT EXISTS (
+		SELECT 1 FROM notification_preferences
+		WHERE user_id = $1 AND kind = $3 AND enabled = false
+	)
+	RETURNING id, created_at`
+	args := []interface{}{n.UserID, n.ActorID, n.Kind, n.BlogID, n.Message}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&n.ID, &n.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (m NotificationModel) GetAllForUser(userID int, unreadOnly bool, filters Filters) ([]Notification, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), id, user_id, actor_id, kind, blog_id, message, read_at IS NOT NULL, created_at
+	FROM notifications
+	WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+	ORDER BY id DESC
+	LIMIT $3 OFFSET $4`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, unreadOnly, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		err := rows.Scan(&totalRecords, &n.ID, &n.UserID, &n.ActorID, &n.Kind, &n.BlogID, &n.Message, &n.Read, &n.CreatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		notifications = append(notifications, n)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	return notifications, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (m NotificationModel) UnreadCount(userID int) (int, error) {
+	query := `SELECT count(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks the given notifications of userID as read, or all of them when ids is empty.
+func (m NotificationModel) MarkRead(userID int, ids []int) (int64, error) {
+	query := `
+	UPDATE notifications SET read_at = NOW()
+	WHERE user_id = $1 AND read_at IS NULL
+	AND (cardinality($2::bigint[]) = 0 OR id = ANY($2))`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if ids == nil {
+		ids = []int{}
+	}
+	result, err := m.DB.ExecContext(ctx, query, userID, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetPreferences returns whether each notification kind is enabled for userID.
+// Kinds without a stored preference are enabled.
+func (m NotificationModel) GetPreferences(userID int) (map[string]bool, error) {
+	query := `SELECT kind, enabled FROM notification_preferences WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preferences := make(map[string]bool)
+	for _, kind := range NotificationKinds {
+		preferences[kind] = true
+	}
+	for rows.Next() {
+		var kind string
+		var enabled bool
+		err := rows.Scan(&kind, &enabled)
+		if err != nil {
+			return nil, err
+		}
+		preferences[kind] = enabled
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+func (m NotificationModel) SetPreference(userID int, kind string, enabled bool) error {
+	query := `
+	INSERT INTO notification_preferences (user_id, kind, enabled)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, kind) DO UPDATE SET enabled = EXCLUDED.enabled`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, kind, enabled)
+	return err
+}
@@ -0,0 +1,155 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+const (
+	PermissionBlogsWrite    = "blogs:write"
+	PermissionBlogsEdit     = "blogs:edit"
+	PermissionBlogsModerate = "blogs:moderate"
+	PermissionUsersAdmin    = "users:admin"
+)
+
+var PermissionCodes = []string{PermissionBlogsWrite, PermissionBlogsEdit, PermissionBlogsModerate, PermissionUsersAdmin}
+
+const (
+	RoleAuthor = "author"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+var Roles = []string{RoleAuthor, RoleEditor, RoleAdmin}
+
+type Permissions []string
+
+func (p Permissions) Include(code string) bool {
+	for _, permission := range p {
+		if permission == code {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidateRoles(v *validator.Validator, roles []string) {
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		v.Check(validator.In(role, Roles...), "roles", "must only contain author, editor or admin")
+		v.Check(!seen[role], "roles", "must not contain duplicate values")
+		seen[role] = true
+	}
+}
+
+type PermissionModel struct {
+	DB *sql.DB
+}
+
+// GetAllForUser returns the permission codes granted to userID through its roles.
+func (m PermissionModel) GetAllForUser(userID int) (Permissions, error) {
+	query := `
+	SELECT DISTINCT permissions.code
+	FROM permissions
+	INNER JOIN roles_permissions ON roles_permissions.permission_id = permissions.id
+	INNER JOIN users_roles ON users_roles.role_id = roles_permissions.role_id
+	WHERE users_roles.user_id = $1
+	ORDER BY permissions.code`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := Permissions{}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, code)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+type RoleModel struct {
+	DB *sql.DB
+}
+
+func (m RoleModel) GetAllForUser(userID int) ([]string, error) {
+	query := `
+	SELECT roles.name FROM roles
+	INNER JOIN users_roles ON users_roles.role_id = roles.id
+	WHERE users_roles.user_id = $1
+	ORDER BY roles.name`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		roles = append(roles, name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// AddForEmail grants roles to the user with email. It does nothing when no such user exists.
+func (m RoleModel) AddForEmail(email string, roles ...string) error {
+	query := `
+	INSERT INTO users_roles (user_id, role_id)
+	SELECT users.id, roles.id FROM users, roles
+	WHERE users.email = $1 AND roles.name = ANY($2)
+	ON CONFLICT DO NOTHING`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, email, pq.Array(roles))
+	return err
+}
+
+// SetForUser replaces the roles of userID with roles.
+func (m RoleModel) SetForUser(userID int, roles []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM users_roles WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO users_roles (user_id, role_id)
+	SELECT $1, roles.id FROM roles WHERE roles.name = ANY($2)`
+	_, err = tx.ExecContext(ctx, query, userID, pq.Array(roles))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
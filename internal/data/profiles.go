@@ -0,0 +1,193 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+var ErrDuplicateUsername = errors.New("duplicate username")
+
+// usernameRX only allows slugs, so usernames can be used in URLs as they are.
+var usernameRX = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// mentionRX matches @username where the @ does not continue a word, so email
+// addresses are not taken for mentions.
+var mentionRX = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_@.-])@([a-zA-Z0-9]+(?:-[a-zA-Z0-9]+)*)`)
+
+// MaxMentions is the most users a single text can notify.
+const MaxMentions = 10
+
+var reservedUsernames = []string{"admin", "administrator", "api", "goblog", "me", "moderator", "root", "support"}
+
+// Profile is the public identity of a user. Username is empty until the user picks one,
+// and only users with a username have a public author page.
+type Profile struct {
+	UserID      int    `json:"-"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Avatar      string `json:"avatar"`
+	Website     string `json:"website"`
+}
+
+// AuthorCounts are the public counts shown on an author page. Hidden posts are not counted.
+type AuthorCounts struct {
+	Posts     int `json:"posts"`
+	Followers int `json:"followers"`
+	Following int `json:"following"`
+}
+
+func ValidateProfile(v *validator.Validator, profile *Profile) {
+	if profile.Username != "" {
+		v.Check(len(profile.Username) >= 3, "username", "must be at least 3 characters long")
+		v.Check(len(profile.Username) <= 30, "username", "must not be more than 30 characters long")
+		v.Check(usernameRX.MatchString(profile.Username), "username", "must only contain lowercase letters, digits and single hyphens between them")
+		v.Check(!validator.In(profile.Username, reservedUsernames...), "username", "is reserved")
+	}
+	v.Check(utf8.RuneCountInString(profile.DisplayName) <= 100, "display_name", "must not be more than 100 characters long")
+	v.Check(utf8.RuneCountInString(profile.Bio) <= 500, "bio", "must not be more than 500 characters long")
+	validateProfileURL(v, "avatar", profile.Avatar)
+	validateProfileURL(v, "website", profile.Website)
+}
+
+func validateProfileURL(v *validator.Validator, key, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	v.Check(len(value) <= 500, key, "must not be more than 500 bytes long")
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", key, "must be a valid http or https url")
+}
+
+// ExtractMentions returns the lowercased usernames mentioned in text, in order of first
+// mention and without duplicates, up to MaxMentions of them.
+func ExtractMentions(text string) []string {
+	seen := make(map[string]bool)
+	usernames := []string{}
+	for _, match := range mentionRX.FindAllStringSubmatch(text, -1) {
+		username := strings.ToLower(match[1])
+		if seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+		if len(usernames) == MaxMentions {
+			break
+		}
+	}
+	return usernames
+}
+
+type ProfileModel struct {
+	DB *sql.DB
+}
+
+func (m ProfileModel) Get(userID int) (*Profile, error) {
+	query := `
+	SELECT id, COALESCE(username, ''), display_name, bio, avatar, website
+	FROM users WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var p Profile
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&p.UserID, &p.Username, &p.DisplayName, &p.Bio, &p.Avatar, &p.Website)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// GetAuthor returns the profile and counts of the author with username. Authors whose
+// accounts are not activated, are suspended or are pending deletion are not public.
+func (m ProfileModel) GetAuthor(username string) (*Profile, *AuthorCounts, error) {
+	query := `
+	SELECT id, username, display_name, bio, avatar, website,
+	(SELECT count(*) FROM blogs WHERE user_id = users.id AND hidden = false),
+	(SELECT count(*) FROM follows WHERE followed_id = users.id),
+	(SELECT count(*) FROM follows WHERE follower_id = users.id)
+	FROM users
+	WHERE username = $1 AND activated = true AND suspended = false
+	AND NOT EXISTS (SELECT 1 FROM account_deletions WHERE user_id = users.id)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var p Profile
+	var counts AuthorCounts
+	err := m.DB.QueryRowContext(ctx, query, username).Scan(
+		&p.UserID, &p.Username, &p.DisplayName, &p.Bio, &p.Avatar, &p.Website,
+		&counts.Posts, &counts.Followers, &counts.Following,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrNoRows
+		default:
+			return nil, nil, err
+		}
+	}
+	return &p, &counts, nil
+}
+
+func (m ProfileModel) Update(p *Profile) error {
+	query := `
+	UPDATE users
+	SET username = NULLIF($1, ''), display_name = $2, bio = $3, avatar = $4, website = $5
+	WHERE id = $6`
+	args := []interface{}{p.Username, p.DisplayName, p.Bio, p.Avatar, p.Website, p.UserID}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+			return ErrDuplicateUsername
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserIDs returns the ids of the activated, unsuspended users with the given
+// usernames. Unknown usernames are skipped.
+func (m ProfileModel) GetUserIDs(usernames []string) ([]int, error) {
+	query := `
+	SELECT id FROM users
+	WHERE username = ANY($1) AND activated = true AND suspended = false`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
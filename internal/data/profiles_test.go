@@ -0,0 +1,42 @@
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"none", "no mentions here", []string{}},
+		{"start of text", "@alice thanks", []string{"alice"}},
+		{"several", "thanks @alice and @bob-smith!", []string{"alice", "bob-smith"}},
+		{"lowercased and deduplicated", "@Alice @alice @ALICE", []string{"alice"}},
+		{"punctuation before", "(@alice),@bob", []string{"alice", "bob"}},
+		{"email is not a mention", "mail bob@example.com", []string{}},
+		{"double at", "@@alice", []string{}},
+		{"trailing hyphen", "@alice- hi", []string{"alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractMentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractMentions(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractMentionsIsCapped(t *testing.T) {
+	var text strings.Builder
+	for i := 0; i < MaxMentions+5; i++ {
+		text.WriteString(" @user")
+		text.WriteByte(byte('a' + i))
+	}
+	if got := ExtractMentions(text.String()); len(got) != MaxMentions {
+		t.Fatalf("got %d mentions, want %d", len(got), MaxMentions)
+	}
+}
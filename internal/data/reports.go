@@ -0,0 +1,229 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+const (
+	ReportOpen      = "open"
+	ReportDismissed = "dismissed"
+	ReportActioned  = "actioned"
+)
+
+var ReportStatuses = []string{ReportOpen, ReportDismissed, ReportActioned}
+
+const (
+	ActionHidePost      = "hide_post"
+	ActionDismiss       = "dismiss"
+	ActionSuspendAuthor = "suspend_author"
+)
+
+var ModerationActions = []string{ActionHidePost, ActionDismiss, ActionSuspendAuthor}
+
+// Actions that undo a moderation decision. They are not taken on a report.
+const (
+	ActionUnhidePost    = "unhide_post"
+	ActionUnsuspendUser = "unsuspend_user"
+)
+
+var ErrDuplicateReport = errors.New("duplicate report")
+
+type Report struct {
+	ID         int        `json:"id"`
+	BlogID     int        `json:"blog_id"`
+	BlogTitle  string     `json:"blog_title,omitempty"`
+	AuthorID   int        `json:"author_id,omitempty"`
+	ReporterID int        `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy *int       `json:"resolved_by,omitempty"`
+}
+
+func ValidateReport(v *validator.Validator, report *Report) {
+	v.Check(report.Reason != "", "reason", "must be provided")
+	v.Check(len(report.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+}
+
+type ReportModel struct {
+	DB *sql.DB
+}
+
+func (m ReportModel) Insert(report *Report) error {
+	query := `
+	INSERT INTO reports (blog_id, reporter_id, reason)
+	VALUES ($1, $2, $3)
+	RETURNING id, status, created_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, report.BlogID, report.ReporterID, report.Reason).Scan(&report.ID, &report.Status, &report.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "reports_open_blog_reporter_idx"`:
+			return ErrDuplicateReport
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m ReportModel) Get(id int) (*Report, error) {
+	query := `
+	SELECT reports.id, reports.blog_id, blogs.title, blogs.user_id, reports.reporter_id, reports.reason,
+	reports.status, reports.created_at, reports.resolved_at, reports.resolved_by
+	FROM reports
+	INNER JOIN blogs ON blogs.id = reports.blog_id
+	WHERE reports.id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var r Report
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&r.ID, &r.BlogID, &r.BlogTitle, &r.AuthorID, &r.ReporterID, &r.Reason,
+		&r.Status, &r.CreatedAt, &r.ResolvedAt, &r.ResolvedBy,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return &r, nil
+}
+
+// GetAll returns reports with the given status, oldest first so the queue is
+// worked in the order reports arrived. An empty status returns every report.
+func (m ReportModel) GetAll(status string, filters Filters) ([]Report, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), reports.id, reports.blog_id, blogs.title, blogs.user_id, reports.reporter_id,
+	reports.reason, reports.status, reports.created_at, reports.resolved_at, reports.resolved_by
+	FROM reports
+	INNER JOIN blogs ON blogs.id = reports.blog_id
+	WHERE ($1 = '' OR reports.status = $1)
+	ORDER BY reports.created_at, reports.id
+	LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	reports := []Report{}
+	for rows.Next() {
+		var r Report
+		err := rows.Scan(
+			&totalRecords, &r.ID, &r.BlogID, &r.BlogTitle, &r.AuthorID, &r.ReporterID,
+			&r.Reason, &r.Status, &r.CreatedAt, &r.ResolvedAt, &r.ResolvedBy,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		reports = append(reports, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	return reports, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Resolve closes the open report id with status. It returns ErrNoRows when the
+// report does not exist or was already resolved.
+func (m ReportModel) Resolve(id int, status string, moderatorID int) error {
+	query := `
+	UPDATE reports SET status = $1, resolved_at = NOW(), resolved_by = $2
+	WHERE id = $3 AND status = 'open'`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, status, moderatorID, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// ResolveOpenForBlog closes every other open report on blogID with status and
+// returns the reporters, so they can be told the post was dealt with.
+func (m ReportModel) ResolveOpenForBlog(blogID int, status string, moderatorID int) ([]int, error) {
+	query := `
+	UPDATE reports SET status = $1, resolved_at = NOW(), resolved_by = $2
+	WHERE blog_id = $3 AND status = 'open'
+	RETURNING reporter_id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, status, moderatorID, blogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reporters []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		reporters = append(reporters, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return reporters, nil
+}
+
+// ModerationAction records a moderation decision. ReportID is nil for the actions that
+// undo one, and BlogID is zero for those that only concern a user.
+type ModerationAction struct {
+	ID           int       `json:"id"`
+	ReportID     *int      `json:"report_id,omitempty"`
+	ModeratorID  int       `json:"moderator_id"`
+	Action       string    `json:"action"`
+	BlogID       int       `json:"blog_id,omitempty"`
+	TargetUserID int       `json:"target_user_id"`
+	Note         string    `json:"note,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func ValidateModerationAction(v *validator.Validator, action *ModerationAction) {
+	v.Check(action.Action != "", "action", "must be provided")
+	v.Check(validator.In(action.Action, ModerationActions...), "action", "must be one of hide_post, dismiss or suspend_author")
+	v.Check(len(action.Note) <= 1000, "note", "must not be more than 1000 bytes long")
+}
+
+type ModerationActionModel struct {
+	DB *sql.DB
+}
+
+func (m ModerationActionModel) Insert(action *ModerationAction) error {
+	query := `
+	INSERT INTO moderation_actions (report_id, moderator_id, action, blog_id, target_user_id, note)
+	VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6)
+	RETURNING id, created_at`
+	args := []interface{}{action.ReportID, action.ModeratorID, action.Action, action.BlogID, action.TargetUserID, action.Note}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&action.ID, &action.CreatedAt)
+}
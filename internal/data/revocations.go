@@ -0,0 +1,68 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Revocation makes stateless access tokens of a user unusable before they expire.
+// Family limits it to the tokens of one session. Without one it covers every token
+// issued to the user up to RevokedAt. Rows are kept until the longest lived token they
+// could cover has expired, which keeps the list small.
+type Revocation struct {
+	UserID    int
+	Family    int
+	RevokedAt time.Time
+	Expiry    time.Time
+}
+
+type RevocationModel struct {
+	DB *sql.DB
+}
+
+// Insert stores revocation. RevokedAt is set by the caller, on the same clock the
+// tokens it covers were issued with.
+func (m RevocationModel) Insert(revocation *Revocation) error {
+	query := `
+	INSERT INTO token_revocations (user_id, family, revoked_at, expiry)
+	VALUES ($1, NULLIF($2, 0), $3, $4)`
+	args := []interface{}{revocation.UserID, revocation.Family, revocation.RevokedAt, revocation.Expiry}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetAllActive deletes expired revocations and returns the rest.
+func (m RevocationModel) GetAllActive() ([]Revocation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM token_revocations WHERE expiry <= NOW()`)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT user_id, COALESCE(family, 0), revoked_at, expiry FROM token_revocations`
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revocations := []Revocation{}
+	for rows.Next() {
+		var revocation Revocation
+		err := rows.Scan(&revocation.UserID, &revocation.Family, &revocation.RevokedAt, &revocation.Expiry)
+		if err != nil {
+			return nil, err
+		}
+		revocations = append(revocations, revocation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return revocations, nil
+}
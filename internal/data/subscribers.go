@@ -0,0 +1,137 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrAlreadyConfirmed = errors.New("subscription already confirmed")
+
+// Subscriber is a reader without an account who receives new posts by email.
+// A nil AuthorID subscribes to every post on the site.
+type Subscriber struct {
+	ID             int       `json:"id"`
+	Email          string    `json:"email"`
+	AuthorID       *int      `json:"author_id,omitempty"`
+	Confirmed      bool      `json:"confirmed"`
+	UnsubscribeKey string    `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type SubscriberModel struct {
+	DB *sql.DB
+}
+
+// Subscribe creates an unconfirmed subscription, or refreshes the confirmation token of
+// an existing unconfirmed one, and returns the confirmation token. It returns
+// ErrAlreadyConfirmed when the subscription exists and is confirmed.
+func (m SubscriberModel) Subscribe(s *Subscriber, ttl time.Duration) (*Token, error) {
+	token, err := generateToken(0, ttl, ScopeSubscription)
+	if err != nil {
+		return nil, err
+	}
+	key, err := generateToken(0, 0, ScopeSubscription)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	INSERT INTO subscribers (email, author_id, token_hash, token_expiry, unsubscribe_key)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (email, (COALESCE(author_id, 0))) DO UPDATE
+	SET token_hash = EXCLUDED.token_hash, token_expiry = EXCLUDED.token_expiry
+	WHERE subscribers.confirmed = false
+	RETURNING id, created_at`
+	args := []interface{}{s.Email, s.AuthorID, token.Hash, token.Expiry, key.Plaintext}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrAlreadyConfirmed
+		default:
+			return nil, err
+		}
+	}
+	return token, nil
+}
+
+func (m SubscriberModel) Confirm(tokenPlaintext string) (*Subscriber, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	query := `
+	UPDATE subscribers
+	SET confirmed = true, confirmed_at = NOW(), token_hash = NULL, token_expiry = NULL
+	WHERE token_hash = $1 AND token_expiry > $2
+	RETURNING id, email, author_id, confirmed, unsubscribe_key, created_at`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var s Subscriber
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:], time.Now()).Scan(
+		&s.ID, &s.Email, &s.AuthorID, &s.Confirmed, &s.UnsubscribeKey, &s.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return &s, nil
+}
+
+// DeleteByKey deletes every subscription of the email address that unsubscribeKey
+// belongs to, whichever of them the key came from.
+func (m SubscriberModel) DeleteByKey(unsubscribeKey string) (int64, error) {
+	query := `
+	DELETE FROM subscribers
+	WHERE lower(email) = (SELECT lower(email) FROM subscribers WHERE unsubscribe_key = $1)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, unsubscribeKey)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetConfirmedForAuthor returns confirmed subscribers to authorID or to the whole site,
+// one per email address regardless of case, ordered by lowercased email and starting
+// after afterEmail.
+func (m SubscriberModel) GetConfirmedForAuthor(authorID int, afterEmail string, limit int) ([]Subscriber, error) {
+	query := `
+	SELECT DISTINCT ON (lower(email)) id, lower(email), author_id, confirmed, unsubscribe_key, created_at
+	FROM subscribers
+	WHERE confirmed = true AND (author_id IS NULL OR author_id = $1) AND lower(email) > lower($2)
+	ORDER BY lower(email), author_id NULLS LAST
+	LIMIT $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, authorID, afterEmail, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscribers []Subscriber
+	for rows.Next() {
+		var s Subscriber
+		err := rows.Scan(&s.ID, &s.Email, &s.AuthorID, &s.Confirmed, &s.UnsubscribeKey, &s.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return subscribers, nil
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Tag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type TagModel struct {
+	DB *sql.DB
+}
+
+// Upsert inserts the tag, or loads the existing tag with the same slug into it.
+func (m TagModel) Upsert(tag *Tag) error {
+	query := `
+	INSERT INTO tags (name, slug)
+	VALUES ($1, $2)
+	ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+	RETURNING id, name`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, tag.Name, tag.Slug).Scan(&tag.ID, &tag.Name)
+}
+
+func (m TagModel) GetBySlug(slug string) (*Tag, error) {
+	query := `SELECT id, name, slug FROM tags WHERE slug = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var tag Tag
+	err := m.DB.QueryRowContext(ctx, query, slug).Scan(&tag.ID, &tag.Name, &tag.Slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return &tag, nil
+}
+
+func (m TagModel) AddToBlog(blogID, tagID int) error {
+	query := `
+	INSERT INTO blog_tags (blog_id, tag_id)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, blogID, tagID)
+	return err
+}
+
+func (m TagModel) GetAllForBlog(blogID int) ([]Tag, error) {
+	query := `
+	SELECT tags.id, tags.name, tags.slug
+	FROM tags
+	INNER JOIN blog_tags ON blog_tags.tag_id = tags.id
+	WHERE blog_tags.blog_id = $1
+	ORDER BY tags.name`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, blogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		err := rows.Scan(&tag.ID, &tag.Name, &tag.Slug)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetAllForBlogs returns the tags of every blog in blogIDs, keyed by blog id.
+func (m TagModel) GetAllForBlogs(blogIDs []int) (map[int][]Tag, error) {
+	query := `
+	SELECT blog_tags.blog_id, tags.id, tags.name, tags.slug
+	FROM tags
+	INNER JOIN blog_tags ON blog_tags.tag_id = tags.id
+	WHERE blog_tags.blog_id = ANY($1)
+	ORDER BY tags.name`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(blogIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int][]Tag)
+	for rows.Next() {
+		var blogID int
+		var tag Tag
+		err := rows.Scan(&blogID, &tag.ID, &tag.Name, &tag.Slug)
+		if err != nil {
+			return nil, err
+		}
+		tags[blogID] = append(tags[blogID], tag)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
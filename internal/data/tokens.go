@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base32"
+	"errors"
 	"time"
 
 	"github.com/sulavmhrzn/goblog/internal/validator"
@@ -14,14 +15,43 @@ import (
 const (
 	ScopeAuthentication = "authentication"
 	ScopeActivation     = "activation"
+	ScopeUnsubscribe    = "unsubscribe"
+	ScopeSubscription   = "subscription"
+	ScopePasswordReset  = "password-reset"
+	ScopeEmailChange    = "email-change"
+	ScopeRefresh        = "refresh"
+	ScopeDataExport     = "data-export"
 )
 
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
 type Token struct {
 	Plaintext string `json:"token"`
 	Hash      []byte `json:"-"`
 	UserID    int    `json:"-"`
 	Expiry    time.Time
 	Scope     string `json:"-"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+	Family    int    `json:"-"`
+}
+
+// TokenPair is a short-lived access token and the refresh token used to replace it.
+// Both belong to the same family, which identifies the session they were issued to.
+type TokenPair struct {
+	Access  *Token `json:"authentication_token"`
+	Refresh *Token `json:"refresh_token"`
+}
+
+// Session describes a token family without revealing its tokens.
+type Session struct {
+	ID         int        `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Expiry     time.Time  `json:"expiry"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	Current    bool       `json:"current"`
 }
 
 func generateToken(userID int, ttl time.Duration, scope string) (*Token, error) {
@@ -61,16 +91,109 @@ func (m TokenModel) New(userID int, ttl time.Duration, scope string) (*Token, er
 	return token, err
 }
 
+// NewSession creates an access and a refresh token recording the client they were
+// issued to. A family of zero starts a new session.
+func (m TokenModel) NewSession(userID, family int, accessTTL, refreshTTL time.Duration, ip, userAgent string) (*TokenPair, error) {
+	access, err := generateToken(userID, accessTTL, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := generateToken(userID, refreshTTL, ScopeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range []*Token{access, refresh} {
+		token.IP = ip
+		token.UserAgent = userAgent
+		token.Family = family
+		err = m.Insert(token)
+		if err != nil {
+			return nil, err
+		}
+		family = token.Family
+	}
+	return &TokenPair{Access: access, Refresh: refresh}, nil
+}
+
+// NewRefreshToken creates just the refresh token of a session, for access tokens that
+// are not stored. A family of zero starts a new session.
+func (m TokenModel) NewRefreshToken(userID, family int, ttl time.Duration, ip, userAgent string) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	token.IP = ip
+	token.UserAgent = userAgent
+	token.Family = family
+	err = m.Insert(token)
+	return token, err
+}
+
+// Insert stores token. A token without a family starts a new one, which is set on token.
 func (m TokenModel) Insert(token *Token) error {
 	query := `
-	INSERT INTO tokens (hash, user_id, expiry, scope)
-	VALUES ($1, $2, $3, $4)`
+	INSERT INTO tokens (hash, user_id, expiry, scope, ip, user_agent, family)
+	VALUES ($1, $2, $3, $4, $5, $6, COALESCE(NULLIF($7, 0), nextval('token_families_seq')))
+	RETURNING family`
 
-	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope}
+	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope, token.IP, token.UserAgent, token.Family}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, args...)
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&token.Family)
+}
+
+// UseRefreshToken marks the refresh token as used and returns its user and family.
+// Presenting a refresh token that was already used means it leaked, so the whole
+// family is deleted and ErrRefreshTokenReused returned along with its user and family.
+func (m TokenModel) UseRefreshToken(tokenPlaintext string) (int, int, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	query := `
+	UPDATE tokens SET used_at = NOW()
+	WHERE hash = $1 AND scope = $2 AND used_at IS NULL AND expiry > NOW()
+	RETURNING user_id, family`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var userID, family int
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:], ScopeRefresh).Scan(&userID, &family)
+	if err == nil {
+		return userID, family, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, err
+	}
+
+	query = `
+	DELETE FROM tokens WHERE family = (
+		SELECT family FROM tokens WHERE hash = $1 AND scope = $2 AND used_at IS NOT NULL
+	)
+	RETURNING user_id, family`
+	rows, err := m.DB.QueryContext(ctx, query, tokenHash[:], ScopeRefresh)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		err = rows.Scan(&userID, &family)
+		if err != nil {
+			return 0, 0, err
+		}
+		return userID, family, ErrRefreshTokenReused
+	}
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, ErrNoRows
+}
+
+// DeleteAccessTokensForFamily deletes the access tokens of family, so a rotated
+// session only keeps its newest access token.
+func (m TokenModel) DeleteAccessTokensForFamily(family int) error {
+	query := `DELETE FROM tokens WHERE family = $1 AND scope = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, family, ScopeAuthentication)
 	return err
 }
 
@@ -79,9 +202,134 @@ func (m TokenModel) DeleteAllForUser(tokenScope string, userID int) error {
 	DELETE FROM tokens WHERE user_id = $1 AND scope=$2`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	_, err := m.DB.ExecContext(ctx, query, userID, ScopeActivation)
+	_, err := m.DB.ExecContext(ctx, query, userID, tokenScope)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// RevokeAllForUser deletes every token of userID, whatever its scope.
+func (m TokenModel) RevokeAllForUser(userID int) error {
+	query := `DELETE FROM tokens WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, userID)
+	return err
+}
+
+// DeleteSessionsForUser deletes the access and refresh tokens of userID except those
+// of keepFamily, so the session making the request stays signed in. A keepFamily of
+// zero signs the user out everywhere. It returns the families that were deleted.
+func (m TokenModel) DeleteSessionsForUser(userID, keepFamily int) ([]int, error) {
+	query := `
+	DELETE FROM tokens
+	WHERE user_id = $1 AND scope IN ($2, $3) AND family != $4
+	RETURNING family`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, ScopeAuthentication, ScopeRefresh, keepFamily)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[int]bool)
+	families := []int{}
+	for rows.Next() {
+		var family int
+		if err := rows.Scan(&family); err != nil {
+			return nil, err
+		}
+		if !seen[family] {
+			seen[family] = true
+			families = append(families, family)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return families, nil
+}
+
+// FamilyForToken returns the session family of the token tokenPlaintext.
+func (m TokenModel) FamilyForToken(tokenPlaintext string) (int, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	query := `SELECT family FROM tokens WHERE hash = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var family int
+	err := m.DB.QueryRowContext(ctx, query, tokenHash[:]).Scan(&family)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNoRows
+		default:
+			return 0, err
+		}
+	}
+	return family, nil
+}
+
+// Touch records that the token was just used. To keep authentication cheap it
+// only writes when the last recorded use is more than a minute old.
+func (m TokenModel) Touch(tokenPlaintext string) error {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	query := `
+	UPDATE tokens SET last_used_at = NOW()
+	WHERE hash = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, tokenHash[:])
+	return err
+}
+
+// GetSessionsForUser returns the signed in sessions of userID, most recently started
+// first. The session currentFamily is marked as current.
+func (m TokenModel) GetSessionsForUser(userID, currentFamily int) ([]Session, error) {
+	query := `
+	SELECT family, min(created_at), max(last_used_at), max(expiry),
+	(array_agg(ip ORDER BY id DESC))[1], (array_agg(user_agent ORDER BY id DESC))[1],
+	family = $4
+	FROM tokens
+	WHERE user_id = $1 AND scope IN ($2, $3) AND used_at IS NULL AND expiry > NOW()
+	GROUP BY family
+	ORDER BY min(created_at) DESC, family DESC`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, ScopeAuthentication, ScopeRefresh, currentFamily)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		err := rows.Scan(&s.ID, &s.CreatedAt, &s.LastUsedAt, &s.Expiry, &s.IP, &s.UserAgent, &s.Current)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// DeleteSession deletes the tokens of the session family id belonging to userID.
+func (m TokenModel) DeleteSession(userID, id int) (int64, error) {
+	query := `DELETE FROM tokens WHERE family = $1 AND user_id = $2 AND scope IN ($3, $4)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID, ScopeAuthentication, ScopeRefresh)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
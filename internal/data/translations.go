@@ -0,0 +1,166 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+var localeRX = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// TranslationRef names one language a blog is available in, for hreflang links.
+type TranslationRef struct {
+	Locale   string `json:"locale"`
+	Slug     string `json:"slug"`
+	Original bool   `json:"original,omitempty"`
+}
+
+type BlogTranslation struct {
+	BlogID    int       `json:"blog_id"`
+	Locale    string    `json:"locale"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NormalizeLocale returns locale with the conventional case of language tags: the
+// language in lower case, a four letter script in title case and a two letter region
+// in upper case, so "NE-np" is stored as "ne-NP".
+func NormalizeLocale(locale string) string {
+	subtags := strings.Split(strings.TrimSpace(locale), "-")
+	for i, subtag := range subtags {
+		subtag = strings.ToLower(subtag)
+		switch {
+		case i > 0 && len(subtag) == 2:
+			subtag = strings.ToUpper(subtag)
+		case i > 0 && len(subtag) == 4:
+			subtag = strings.ToUpper(subtag[:1]) + subtag[1:]
+		}
+		subtags[i] = subtag
+	}
+	return strings.Join(subtags, "-")
+}
+
+func ValidateLocale(v *validator.Validator, locale string) {
+	v.Check(locale != "", "locale", "must be provided")
+	v.Check(len(locale) <= 35 && localeRX.MatchString(locale), "locale", "must be a valid language tag such as en or ne-NP")
+}
+
+func ValidateBlogTranslation(v *validator.Validator, t *BlogTranslation) {
+	v.Check(t.Title != "", "title", "must be provided")
+	v.Check(len(t.Title) >= 2, "title", "must be greater than 2 characters")
+	v.Check(t.Content != "", "content", "must be provided")
+	v.Check(len(t.Content) >= 5, "content", "must be greater than 5 characters")
+	ValidateLocale(v, t.Locale)
+}
+
+// Apply replaces the blog's title, content and slug with the translation.
+func (t BlogTranslation) Apply(b *Blog) {
+	b.Title = t.Title
+	b.Content = t.Content
+	b.Slug = t.Slug
+	b.Locale = t.Locale
+}
+
+type TranslationModel struct {
+	DB *sql.DB
+}
+
+func (m TranslationModel) Upsert(t *BlogTranslation) error {
+	query := `
+	INSERT INTO blog_translations (blog_id, locale, title, content, slug)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (blog_id, locale) DO UPDATE
+	SET title = EXCLUDED.title, content = EXCLUDED.content, slug = EXCLUDED.slug, updated_at = NOW()
+	RETURNING created_at, updated_at`
+	args := []interface{}{t.BlogID, t.Locale, t.Title, t.Content, t.Slug}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	err = touchBlog(ctx, tx, t.BlogID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// touchBlog bumps the blog's updated_at so that cached representations, which
+// include the list of translations, are revalidated.
+func touchBlog(ctx context.Context, tx *sql.Tx, blogID int) error {
+	_, err := tx.ExecContext(ctx, `UPDATE blogs SET updated_at = NOW() WHERE id = $1`, blogID)
+	return err
+}
+
+func (m TranslationModel) Delete(blogID int, locale string) (int64, error) {
+	query := `DELETE FROM blog_translations WHERE blog_id = $1 AND locale = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, blogID, locale)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil || rows == 0 {
+		return rows, err
+	}
+	err = touchBlog(ctx, tx, blogID)
+	if err != nil {
+		return 0, err
+	}
+	return rows, tx.Commit()
+}
+
+// GetAllForBlogs returns the translations of every blog in blogIDs, keyed by blog id.
+func (m TranslationModel) GetAllForBlogs(blogIDs []int) (map[int][]BlogTranslation, error) {
+	query := `
+	SELECT blog_id, locale, title, content, slug, created_at, updated_at
+	FROM blog_translations
+	WHERE blog_id = ANY($1)
+	ORDER BY blog_id, locale`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(blogIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations := make(map[int][]BlogTranslation)
+	for rows.Next() {
+		var t BlogTranslation
+		err := rows.Scan(&t.BlogID, &t.Locale, &t.Title, &t.Content, &t.Slug, &t.CreatedAt, &t.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		translations[t.BlogID] = append(translations[t.BlogID], t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return translations, nil
+}
@@ -0,0 +1,23 @@
+package data
+
+import "testing"
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"en", "en"},
+		{"EN", "en"},
+		{"ne-np", "ne-NP"},
+		{"NE-np", "ne-NP"},
+		{"zh-hant-tw", "zh-Hant-TW"},
+		{"es-419", "es-419"},
+		{" fr ", "fr"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeLocale(tt.locale); got != tt.want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
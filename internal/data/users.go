@@ -2,11 +2,14 @@ package data
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/sulavmhrzn/goblog/internal/validator"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -21,6 +24,7 @@ type User struct {
 	Email     string   `json:"email"`
 	Password  password `json:"-"`
 	Activated bool     `json:"activated"`
+	Suspended bool     `json:"suspended,omitempty"`
 }
 
 type password struct {
@@ -44,11 +48,24 @@ func (p *password) Set(plaintextPassword string) error {
 	return nil
 }
 
+// SetUnusable replaces the password with a random one nobody knows, so the
+// account can only be used again after a password reset.
+func (p *password) SetUnusable() error {
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return err
+	}
+	return p.Set(hex.EncodeToString(random))
+}
+
+// Matches reports whether plaintextPassword is the password. A stored value that is
+// too short to be a bcrypt hash, such as the '*' of the deleted user, matches nothing.
 func (p *password) Matches(plaintextPassword string) (bool, error) {
 	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
 	if err != nil {
 		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword), errors.Is(err, bcrypt.ErrHashTooShort):
 			return false, nil
 		default:
 			return false, err
@@ -78,12 +95,21 @@ type UserModel struct {
 	DB *sql.DB
 }
 
-func (m UserModel) Insert(u *User) error {
+// Insert creates u and grants it roles in the same transaction, so a user never exists
+// without them.
+func (m UserModel) Insert(u *User, roles ...string) error {
 	query := `INSERT INTO users (email, password, activated) VALUES ($1, $2, $3) RETURNING id`
 	args := []interface{}{u.Email, u.Password.hash, u.Activated}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&u.ID)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&u.ID)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -92,16 +118,47 @@ func (m UserModel) Insert(u *User) error {
 			return err
 		}
 	}
-	return nil
+	if len(roles) > 0 {
+		query = `
+		INSERT INTO users_roles (user_id, role_id)
+		SELECT $1, roles.id FROM roles WHERE roles.name = ANY($2)`
+		_, err = tx.ExecContext(ctx, query, u.ID, pq.Array(roles))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
+// GetByEmail returns the user with email, ignoring case. Emails that only differ in
+// case were once separate accounts, so an exact match is preferred over the others.
 func (m UserModel) GetByEmail(email string) (*User, error) {
-	query := `SELECT id, email, password, activated FROM users
-	WHERE email = $1`
+	query := `SELECT id, email, password, activated, suspended FROM users
+	WHERE lower(email) = lower($1)
+	ORDER BY email = $1 DESC, id
+	LIMIT 1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var user User
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Email, &user.Password.hash, &user.Activated, &user.Suspended)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+func (m UserModel) Get(id int) (*User, error) {
+	query := `SELECT id, email, password, activated, suspended FROM users
+	WHERE id = $1`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	var user User
-	err := m.DB.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Email, &user.Password.hash, &user.Activated)
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Email, &user.Password.hash, &user.Activated, &user.Suspended)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -116,7 +173,7 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 	query := `
-	SELECT users.id, users.email, users.password, users.activated
+	SELECT users.id, users.email, users.password, users.activated, users.suspended
 	FROM users
 	INNER JOIN tokens
 	ON users.id = tokens.user_id
@@ -135,6 +192,7 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.Suspended,
 	)
 	if err != nil {
 		switch {
@@ -167,9 +225,117 @@ func (m UserModel) Update(user *User) error {
 	return nil
 }
 
+func (m UserModel) SetSuspended(userID int, suspended bool) error {
+	query := `UPDATE users SET suspended = $1 WHERE id = $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, suspended, userID)
+	return err
+}
+
+// GetAll returns users whose email contains search, ordered by id. An empty
+// search returns every user.
+func (m UserModel) GetAll(search string, filters Filters) ([]User, Metadata, error) {
+	query := `
+	SELECT count(*) OVER(), id, email, activated, suspended
+	FROM users
+	WHERE ($1 = '' OR email ILIKE '%' || $1 || '%')
+	ORDER BY id
+	LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, search, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	users := []User{}
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&totalRecords, &user.ID, &user.Email, &user.Activated, &user.Suspended)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+	return users, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (m UserModel) Delete(id int) (int64, error) {
+	query := `DELETE FROM users WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SetPendingEmail records newEmail as the address userID wants to change to,
+// replacing any earlier pending change.
+func (m UserModel) SetPendingEmail(userID int, newEmail string) error {
+	query := `
+	INSERT INTO email_changes (user_id, new_email)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET new_email = EXCLUDED.new_email, created_at = NOW()`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, newEmail)
+	return err
+}
+
+// TakePendingEmail removes and returns the pending email change of userID.
+func (m UserModel) TakePendingEmail(userID int) (string, error) {
+	query := `DELETE FROM email_changes WHERE user_id = $1 RETURNING new_email`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var email string
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&email)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrNoRows
+		default:
+			return "", err
+		}
+	}
+	return email, nil
+}
+
+type PostCounts struct {
+	Total  int `json:"total"`
+	Hidden int `json:"hidden"`
+}
+
+func (m UserModel) PostCounts(userID int) (*PostCounts, error) {
+	query := `
+	SELECT count(*), count(*) FILTER (WHERE hidden)
+	FROM blogs WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var counts PostCounts
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&counts.Total, &counts.Hidden)
+	if err != nil {
+		return nil, err
+	}
+	return &counts, nil
+}
+
 type UserDashboardDetails struct {
-	User  User
-	Blogs []Blog
+	User    User
+	Blogs   []Blog
+	Follows FollowCounts
 }
 
 func (m UserModel) DashboardDetails(userID int) (*UserDashboardDetails, error) {
@@ -181,13 +347,22 @@ func (m UserModel) DashboardDetails(userID int) (*UserDashboardDetails, error) {
 	WHERE blogs.user_id = $1
 	`
 	userQuery := `
-	SELECT id, email, activated FROM users WHERE id = $1`
+	SELECT id, email, activated,
+	(SELECT count(*) FROM follows WHERE followed_id = users.id),
+	(SELECT count(*) FROM follows WHERE follower_id = users.id)
+	FROM users WHERE id = $1`
 	var dashboard UserDashboardDetails
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, userQuery, userID).Scan(&dashboard.User.ID, &dashboard.User.Email, &dashboard.User.Activated)
+	err := m.DB.QueryRowContext(ctx, userQuery, userID).Scan(
+		&dashboard.User.ID,
+		&dashboard.User.Email,
+		&dashboard.User.Activated,
+		&dashboard.Follows.Followers,
+		&dashboard.Follows.Following,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,38 @@
+package data
+
+import "testing"
+
+func TestPasswordMatches(t *testing.T) {
+	var p password
+	if err := p.Set("correct horse battery"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		plaintext string
+		want      bool
+	}{
+		{"correct horse battery", true},
+		{"wrong password", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		got, err := p.Matches(tt.plaintext)
+		if err != nil {
+			t.Fatalf("Matches(%q) returned error %v", tt.plaintext, err)
+		}
+		if got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.plaintext, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordOfDeletedUserMatchesNothing(t *testing.T) {
+	// The deleted user is seeded with '*' rather than a bcrypt hash.
+	p := password{hash: []byte("*")}
+	for _, plaintext := range []string{"*", "", "password"} {
+		got, err := p.Matches(plaintext)
+		if err != nil || got {
+			t.Errorf("Matches(%q) = %v, %v, want false, nil", plaintext, got, err)
+		}
+	}
+}
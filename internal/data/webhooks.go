@@ -0,0 +1,245 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sulavmhrzn/goblog/internal/netaddr"
+	"github.com/sulavmhrzn/goblog/internal/validator"
+)
+
+// Webhook events are delivered to the webhooks of the user they are about: the author
+// of the blog, or for user.activated the user who was activated. That happens when the
+// user redeems their activation token, signs in with an identity provider or is
+// activated by an administrator.
+const (
+	EventBlogCreated   = "blog.created"
+	EventBlogUpdated   = "blog.updated"
+	EventBlogDeleted   = "blog.deleted"
+	EventUserActivated = "user.activated"
+)
+
+var WebhookEvents = []string{EventBlogCreated, EventBlogUpdated, EventBlogDeleted, EventUserActivated}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"-"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func ValidateWebhook(v *validator.Validator, hook *Webhook) {
+	u, err := url.Parse(hook.URL)
+	v.Check(hook.URL != "", "url", "must be provided")
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "must be a valid http or https url")
+	if err == nil {
+		// Names are checked again when delivering, as they may resolve to internal addresses.
+		host := strings.ToLower(u.Hostname())
+		ip := net.ParseIP(host)
+		v.Check(host != "localhost" && !strings.HasSuffix(host, ".localhost") && (ip == nil || netaddr.IsPublic(ip)), "url", "must not point to a local or private network address")
+	}
+	v.Check(len(hook.Events) > 0, "events", "must contain at least one event")
+	for _, event := range hook.Events {
+		v.Check(validator.In(event, WebhookEvents...), "events", "contains an unknown event")
+	}
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+func (m WebhookModel) Insert(webhook *Webhook) error {
+	query := `
+	INSERT INTO webhooks (user_id, url, secret, events)
+	VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	args := []interface{}{webhook.UserID, webhook.URL, webhook.Secret, pq.Array(webhook.Events)}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.ID, &webhook.CreatedAt)
+}
+
+func (m WebhookModel) GetAllForUser(userID int) ([]Webhook, error) {
+	query := `
+	SELECT id, user_id, url, events, created_at FROM webhooks
+	WHERE user_id = $1 ORDER BY id`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var webhook Webhook
+		err := rows.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, pq.Array(&webhook.Events), &webhook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (m WebhookModel) Get(id int) (*Webhook, error) {
+	query := `SELECT id, user_id, url, events, created_at FROM webhooks WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var webhook Webhook
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&webhook.ID, &webhook.UserID, &webhook.URL, pq.Array(&webhook.Events), &webhook.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRows
+		default:
+			return nil, err
+		}
+	}
+	return &webhook, nil
+}
+
+func (m WebhookModel) Delete(id int) error {
+	query := `DELETE FROM webhooks WHERE id = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status"`
+	LastError      string          `json:"last_error"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+}
+
+type WebhookDeliveryModel struct {
+	DB *sql.DB
+}
+
+// Enqueue creates a pending delivery for every webhook of userID that is subscribed to event.
+func (m WebhookDeliveryModel) Enqueue(userID int, event string, payload []byte) error {
+	query := `
+	INSERT INTO webhook_deliveries (webhook_id, event, payload)
+	SELECT id, $2, $3 FROM webhooks
+	WHERE user_id = $1 AND $2 = ANY(events)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, event, payload)
+	return err
+}
+
+// GetDue returns pending deliveries whose next attempt is due, along with the target url and secret.
+func (m WebhookDeliveryModel) GetDue(limit int) ([]WebhookDelivery, error) {
+	query := `
+	SELECT d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.response_status,
+	d.last_error, d.next_attempt_at, d.created_at, d.delivered_at, w.url, w.secret
+	FROM webhook_deliveries d
+	INNER JOIN webhooks w ON w.id = d.webhook_id
+	WHERE d.status = $1 AND d.next_attempt_at <= NOW()
+	ORDER BY d.next_attempt_at
+	LIMIT $2`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, DeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.ResponseStatus,
+			&d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (m WebhookDeliveryModel) Update(d *WebhookDelivery) error {
+	query := `
+	UPDATE webhook_deliveries SET
+	status = $1, attempts = $2, response_status = $3, last_error = $4,
+	next_attempt_at = $5, delivered_at = $6
+	WHERE id = $7`
+	args := []interface{}{d.Status, d.Attempts, d.ResponseStatus, d.LastError, d.NextAttemptAt, d.DeliveredAt, d.ID}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (m WebhookDeliveryModel) GetAllForWebhook(webhookID int) ([]WebhookDelivery, error) {
+	query := `
+	SELECT id, webhook_id, event, payload, status, attempts, response_status,
+	last_error, next_attempt_at, created_at, delivered_at
+	FROM webhook_deliveries
+	WHERE webhook_id = $1
+	ORDER BY id DESC
+	LIMIT 100`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.ResponseStatus,
+			&d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,160 @@
+// Package jwt signs and verifies the HS256 JSON Web Tokens used as stateless access
+// tokens. Every token names its signing key in the kid header, so keys can be rotated
+// by adding a new one, making it active and removing the old one once its tokens expire.
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("expired token")
+)
+
+// MinKeyLength is the shortest secret accepted, the size of the HS256 output.
+const MinKeyLength = 32
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// Claims is the payload of an access token. Subject is the user ID and SessionID the
+// token family of the refresh token it was issued with.
+type Claims struct {
+	Subject   int       `json:"sub,string"`
+	SessionID int       `json:"sid"`
+	IssuedAt  Timestamp `json:"iat"`
+	Expiry    int64     `json:"exp"`
+	Activated bool      `json:"activated"`
+	Scopes    []string  `json:"scopes"`
+}
+
+// Timestamp is a time in milliseconds since the Unix epoch. It is encoded as a
+// NumericDate with a fractional part, which RFC 7519 allows, so a token issued in the
+// same second as a revocation can still be told apart from it.
+type Timestamp int64
+
+// NewTimestamp returns t as a Timestamp.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp(t.UnixMilli())
+}
+
+func (ts Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatFloat(float64(ts)/1000, 'f', 3, 64)), nil
+}
+
+func (ts *Timestamp) UnmarshalJSON(b []byte) error {
+	seconds, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return err
+	}
+	*ts = Timestamp(math.Round(seconds * 1000))
+	return nil
+}
+
+// Keys holds the signing secrets by kid. Tokens are signed with the active key and
+// verified with whichever key they name.
+type Keys struct {
+	active  string
+	secrets map[string][]byte
+}
+
+// ParseKeys reads keys given as comma separated kid:secret pairs.
+func ParseKeys(s, active string) (*Keys, error) {
+	keys := &Keys{active: active, secrets: make(map[string][]byte)}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kid, secret, found := strings.Cut(pair, ":")
+		if !found || kid == "" {
+			return nil, fmt.Errorf("jwt key %q must be given as kid:secret", pair)
+		}
+		if len(secret) < MinKeyLength {
+			return nil, fmt.Errorf("jwt key %q must be at least %d bytes long", kid, MinKeyLength)
+		}
+		if _, exists := keys.secrets[kid]; exists {
+			return nil, fmt.Errorf("jwt key %q is given more than once", kid)
+		}
+		keys.secrets[kid] = []byte(secret)
+	}
+	if _, ok := keys.secrets[active]; !ok {
+		return nil, fmt.Errorf("active jwt key %q is not among the configured keys", active)
+	}
+	return keys, nil
+}
+
+// Sign returns the compact serialization of claims, signed with the active key.
+func (k *Keys) Sign(claims Claims) (string, error) {
+	h, err := json.Marshal(header{Algorithm: "HS256", Type: "JWT", KeyID: k.active})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sign(k.secrets[k.active], signingInput)), nil
+}
+
+// Verify checks the signature and expiry of token and returns its claims.
+func (k *Keys) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if json.Unmarshal(rawHeader, &h) != nil || h.Algorithm != "HS256" {
+		return nil, ErrInvalidToken
+	}
+	secret, ok := k.secrets[h.KeyID]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, sign(secret, parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if json.Unmarshal(payload, &claims) != nil || claims.Subject <= 0 {
+		return nil, ErrInvalidToken
+	}
+	if now.Unix() >= claims.Expiry {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+// IsJWT reports whether a bearer token has the shape of a JWT rather than an opaque token.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func sign(secret []byte, signingInput string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
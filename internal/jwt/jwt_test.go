@@ -0,0 +1,188 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	secretA = "first-secret-that-is-long-enough!"
+	secretB = "second-secret-that-is-long-enough"
+)
+
+func mustParseKeys(t *testing.T, s, active string) *Keys {
+	t.Helper()
+	keys, err := ParseKeys(s, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func testClaims(now time.Time) Claims {
+	return Claims{
+		Subject:   7,
+		SessionID: 3,
+		IssuedAt:  NewTimestamp(now),
+		Expiry:    now.Add(15 * time.Minute).Unix(),
+		Activated: true,
+		Scopes:    []string{"blogs:write"},
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 250*int(time.Millisecond), time.UTC)
+	keys := mustParseKeys(t, "a:"+secretA, "a")
+	token, err := keys.Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := keys.Verify(token, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testClaims(now)
+	if claims.Subject != want.Subject || claims.SessionID != want.SessionID || claims.IssuedAt != want.IssuedAt ||
+		claims.Expiry != want.Expiry || !claims.Activated || len(claims.Scopes) != 1 || claims.Scopes[0] != "blogs:write" {
+		t.Errorf("got %+v, want %+v", *claims, want)
+	}
+}
+
+func TestVerifyAfterKeyRotation(t *testing.T) {
+	now := time.Now()
+	before := mustParseKeys(t, "a:"+secretA, "a")
+	oldToken, err := before.Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// b is added and made active while a is kept for the tokens it signed.
+	during := mustParseKeys(t, "a:"+secretA+",b:"+secretB, "b")
+	newToken, err := during.Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		if _, err := during.Verify(token, now); err != nil {
+			t.Errorf("%s token: %v", name, err)
+		}
+	}
+
+	// Once a is removed, its tokens are refused.
+	after := mustParseKeys(t, "b:"+secretB, "b")
+	if _, err := after.Verify(oldToken, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("old token after removing its key: got %v, want %v", err, ErrInvalidToken)
+	}
+	if _, err := after.Verify(newToken, now); err != nil {
+		t.Errorf("new token after removing the old key: %v", err)
+	}
+}
+
+func TestVerifyRejectsUnknownKid(t *testing.T) {
+	now := time.Now()
+	signer := mustParseKeys(t, "a:"+secretA, "a")
+	token, err := signer.Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The verifier has a key with the same secret under another kid.
+	verifier := mustParseKeys(t, "b:"+secretA, "b")
+	if _, err := verifier.Verify(token, now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestVerifyRejectsTamperedTokens(t *testing.T) {
+	now := time.Now()
+	keys := mustParseKeys(t, "a:"+secretA, "a")
+	token, err := keys.Sign(testClaims(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+
+	forged := testClaims(now)
+	forged.Subject = 1
+	payload, err := json.Marshal(forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	none, err := json.Marshal(header{Algorithm: "none", Type: "JWT", KeyID: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"payload":     parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2],
+		"signature":   parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString([]byte("not the signature")),
+		"alg none":    base64.RawURLEncoding.EncodeToString(none) + "." + parts[1] + ".",
+		"two parts":   parts[0] + "." + parts[1],
+		"bad base64":  parts[0] + ".!!!." + parts[2],
+		"empty token": "",
+	}
+	for name, tampered := range tests {
+		if _, err := keys.Verify(tampered, now); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestVerifyRejectsExpiredTokens(t *testing.T) {
+	now := time.Now()
+	keys := mustParseKeys(t, "a:"+secretA, "a")
+	claims := testClaims(now)
+	token, err := keys.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keys.Verify(token, time.Unix(claims.Expiry-1, 0)); err != nil {
+		t.Errorf("just before expiry: %v", err)
+	}
+	if _, err := keys.Verify(token, time.Unix(claims.Expiry, 0)); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("at expiry: got %v, want %v", err, ErrExpiredToken)
+	}
+}
+
+func TestTimestampJSON(t *testing.T) {
+	ts := NewTimestamp(time.Unix(1700000000, 123*int64(time.Millisecond)))
+	b, err := json.Marshal(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "1700000000.123" {
+		t.Errorf("got %s, want 1700000000.123", b)
+	}
+
+	tests := map[string]Timestamp{
+		"1700000000.123": ts,
+		"1700000000":     Timestamp(1700000000000),
+	}
+	for input, want := range tests {
+		var got Timestamp
+		if err := json.Unmarshal([]byte(input), &got); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if got != want {
+			t.Errorf("%s: got %d, want %d", input, got, want)
+		}
+	}
+}
+
+func TestParseKeysRejectsInvalidConfiguration(t *testing.T) {
+	tests := map[string]struct{ keys, active string }{
+		"short secret":      {"a:short", "a"},
+		"missing separator": {"a" + secretA, "a"},
+		"duplicate kid":     {"a:" + secretA + ",a:" + secretB, "a"},
+		"unknown active":    {"a:" + secretA, "b"},
+	}
+	for name, tt := range tests {
+		if _, err := ParseKeys(tt.keys, tt.active); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -1,11 +1,18 @@
 package mailer
 
 import (
+	"bytes"
+	"embed"
+	"html/template"
+	ttemplate "text/template"
 	"time"
 
 	"github.com/go-mail/mail/v2"
 )
 
+//go:embed "templates"
+var templateFS embed.FS
+
 type Mailer struct {
 	dialer *mail.Dialer
 	sender string
@@ -33,3 +40,51 @@ func (m Mailer) Send(recipient, subject, body string) error {
 	}
 	return nil
 }
+
+// SendTemplate renders the "subject", "plainBody" and "htmlBody" templates defined in
+// templates/templateFile and sends them as a multipart plain text and HTML email.
+// The subject and plain text body are rendered without HTML escaping.
+func (m Mailer) SendTemplate(recipient, templateFile string, data interface{}) error {
+	return m.SendTemplateWithHeaders(recipient, templateFile, data, nil)
+}
+
+// SendTemplateWithHeaders works like SendTemplate and adds headers to the email, such
+// as List-Unsubscribe.
+func (m Mailer) SendTemplateWithHeaders(recipient, templateFile string, data interface{}, headers map[string]string) error {
+	textTmpl, err := ttemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return err
+	}
+	htmlTmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return err
+	}
+
+	subject := new(bytes.Buffer)
+	err = textTmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return err
+	}
+	plainBody := new(bytes.Buffer)
+	err = textTmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return err
+	}
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return err
+	}
+
+	msg := mail.NewMessage()
+	msg.SetHeader("To", recipient)
+	msg.SetHeader("From", m.sender)
+	msg.SetHeader("Subject", subject.String())
+	for name, value := range headers {
+		msg.SetHeader(name, value)
+	}
+	msg.SetBody("text/plain", plainBody.String())
+	msg.AddAlternative("text/html", htmlBody.String())
+
+	return m.dialer.DialAndSend(msg)
+}
@@ -0,0 +1,17 @@
+// Package netaddr tells public addresses apart from internal ones, for the outgoing
+// requests a user can point at an address of their choosing.
+package netaddr
+
+import "net"
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, which net.IP does
+// not count as private.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublic reports whether ip is publicly routable, rather than a loopback,
+// link-local, private or otherwise internal address.
+func IsPublic(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
@@ -0,0 +1,140 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/oidc/oidctest"
+)
+
+const redirectURL = "https://goblog.example/api/v1/oidc/test/callback"
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Server) {
+	t.Helper()
+	server, err := oidctest.NewServer("goblog", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	provider := NewProvider(Config{
+		Name:         "test",
+		Issuer:       server.URL,
+		ClientID:     server.ClientID,
+		ClientSecret: server.ClientSecret,
+		RedirectURL:  redirectURL,
+	}, server.Client())
+	return provider, server
+}
+
+// signIn runs the login step and follows the provider back to the callback URL,
+// returning its query string.
+func signIn(t *testing.T, provider *Provider, state, nonce, challenge string) url.Values {
+	t.Helper()
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned status %d, want %d", res.StatusCode, http.StatusFound)
+	}
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := callback.Scheme + "://" + callback.Host + callback.Path; got != redirectURL {
+		t.Fatalf("redirected to %s, want %s", got, redirectURL)
+	}
+	return callback.Query()
+}
+
+func TestSignInEndToEnd(t *testing.T) {
+	provider, server := newTestProvider(t)
+	server.SetUser(oidctest.User{Subject: "42", Email: "reader@example.com", EmailVerified: true, Name: "Reader"})
+
+	state, nonce := "state-value", "nonce-value"
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := signIn(t, provider, state, nonce, challenge)
+	if callback.Get("state") != state {
+		t.Fatalf("got state %q, want %q", callback.Get("state"), state)
+	}
+
+	raw, err := provider.Exchange(context.Background(), callback.Get("code"), verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := provider.VerifyIDToken(context.Background(), raw, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "42" || claims.Email != "reader@example.com" || !claims.EmailVerified {
+		t.Errorf("got claims %+v", claims)
+	}
+
+	// Codes are single use.
+	_, err = provider.Exchange(context.Background(), callback.Get("code"), verifier)
+	if !errors.Is(err, ErrExchangeFailed) {
+		t.Errorf("reusing the code returned %v, want %v", err, ErrExchangeFailed)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	provider, _ := newTestProvider(t)
+	_, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := signIn(t, provider, "state", "nonce", challenge)
+
+	_, err = provider.Exchange(context.Background(), callback.Get("code"), other)
+	if !errors.Is(err, ErrExchangeFailed) {
+		t.Errorf("got %v, want %v", err, ErrExchangeFailed)
+	}
+}
+
+func TestVerifyIDTokenRejectsWrongNonceAndExpiry(t *testing.T) {
+	provider, _ := newTestProvider(t)
+
+	idToken := func() string {
+		verifier, challenge, err := NewPKCE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		callback := signIn(t, provider, "state", "nonce", challenge)
+		raw, err := provider.Exchange(context.Background(), callback.Get("code"), verifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	_, err := provider.VerifyIDToken(context.Background(), idToken(), "another-nonce")
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("wrong nonce: got %v, want %v", err, ErrInvalidIDToken)
+	}
+
+	raw := idToken()
+	provider.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, err = provider.VerifyIDToken(context.Background(), raw, "nonce")
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("expired token: got %v, want %v", err, ErrInvalidIDToken)
+	}
+}
@@ -0,0 +1,89 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 Appendix B test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// The RFC lists 8 digit codes; with 6 digits the code is their last 6 digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},          // 94287082
+	{1111111109, "081804"},  // 07081804
+	{1111111111, "050471"},  // 14050471
+	{1234567890, "005924"},  // 89005924
+	{2000000000, "279037"},  // 69279037
+	{20000000000, "353130"}, // 65353130
+}
+
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	for _, tt := range rfcVectors {
+		got, err := Code(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d returned error %v", tt.unix, err)
+		}
+		if got != tt.code {
+			t.Errorf("Code at %d = %q, want %q", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Counter(now)
+
+	previous, err := Code(rfcSecret, current-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tooOld, err := Code(rfcSecret, current-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		counter int64
+		ok      bool
+	}{
+		{"current step", "050471", current, true},
+		{"previous step within skew", previous, current - 1, true},
+		{"outside skew", tooOld, 0, false},
+		{"wrong code", "000000", 0, false},
+		{"wrong length", "50471", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, ok := Match(rfcSecret, tt.code, now)
+			if ok != tt.ok || counter != tt.counter {
+				t.Errorf("Match(%q) = %d, %v, want %d, %v", tt.code, counter, ok, tt.counter, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCodeAcceptsLowercaseSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	upper, err := Code(secret, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower, err := Code(strings.ToLower(secret), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upper != lower {
+		t.Errorf("got %q for the lowercase secret, want %q", lower, upper)
+	}
+}
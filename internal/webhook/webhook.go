@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/sulavmhrzn/goblog/internal/netaddr"
+)
+
+const (
+	HeaderEvent     = "X-Goblog-Event"
+	HeaderDelivery  = "X-Goblog-Delivery"
+	HeaderTimestamp = "X-Goblog-Timestamp"
+	HeaderSignature = "X-Goblog-Signature"
+)
+
+// Sign returns the hex encoded HMAC-SHA256 of "timestamp.body" keyed with secret.
+// Receivers recompute it from the X-Goblog-Timestamp header and the raw request body.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid "sha256=" signature for body.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	expected := "sha256=" + Sign(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// ErrForbiddenAddress is returned when a webhook would connect to an address that is
+// not publicly routable, such as loopback, link-local or private network addresses.
+var ErrForbiddenAddress = errors.New("webhook address is not publicly routable")
+
+// dialControl refuses connections to addresses that are not public. It runs after the
+// host is resolved, so a name that resolves to an internal address is refused as well.
+func dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !netaddr.IsPublic(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+type Client struct {
+	client *http.Client
+}
+
+// New returns a Client sending deliveries with client. A nil client uses one that
+// only connects to public addresses and ignores proxy settings, so receivers cannot
+// be pointed at the server's own network.
+func New(client *http.Client) Client {
+	if client == nil {
+		dialer := &net.Dialer{Timeout: 5 * time.Second, Control: dialControl}
+		client = &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second},
+		}
+	}
+	return Client{client: client}
+}
+
+// Deliver posts a signed payload to url and returns the response status code.
+// Any non 2xx response is treated as a failed delivery.
+func (c Client) Deliver(url, secret, event string, deliveryID int, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Goblog-Webhook/1.0")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(deliveryID))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(secret, timestamp, body))
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
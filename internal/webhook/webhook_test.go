@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestDeliverSignsPayload(t *testing.T) {
+	const secret = "s3cret"
+	body := []byte(`{"event":"blog.created"}`)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil || !Verify(secret, timestamp, got, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(HeaderEvent) != "blog.created" || r.Header.Get(HeaderDelivery) != "7" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := New(srv.Client())
+	status, err := client.Deliver(srv.URL, secret, "blog.created", 7, body)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("got status %d, err %v; want 204", status, err)
+	}
+
+	status, err = client.Deliver(srv.URL, "wrong", "blog.created", 7, body)
+	if err == nil || status != http.StatusUnauthorized {
+		t.Fatalf("got status %d, err %v; want 401 and an error", status, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte("payload")
+	signature := "sha256=" + Sign("secret", 100, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid", "secret", 100, body, signature, true},
+		{"wrong secret", "other", 100, body, signature, false},
+		{"wrong timestamp", "secret", 101, body, signature, false},
+		{"tampered body", "secret", 100, []byte("payload!"), signature, false},
+		{"missing prefix", "secret", 100, body, Sign("secret", 100, body), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.timestamp, tt.body, tt.signature); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultClientRefusesLocalAddresses(t *testing.T) {
+	reached := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer srv.Close()
+
+	_, err := New(nil).Deliver(srv.URL, "secret", "blog.created", 1, []byte("{}"))
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("got err %v, want ErrForbiddenAddress", err)
+	}
+	if reached {
+		t.Fatal("request reached the local receiver")
+	}
+}
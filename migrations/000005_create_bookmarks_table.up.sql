@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS bookmarks (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    blog_id bigint NOT NULL REFERENCES blogs ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, blog_id)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    followed_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (follower_id, followed_id),
+    CHECK (follower_id <> followed_id)
+);
+
+CREATE INDEX IF NOT EXISTS follows_followed_id_idx ON follows (followed_id);
+CREATE INDEX IF NOT EXISTS blogs_user_id_created_at_idx ON blogs (user_id, created_at DESC, id DESC);
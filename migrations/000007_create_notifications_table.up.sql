@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS notifications (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    actor_id bigint REFERENCES users ON DELETE CASCADE,
+    kind text NOT NULL,
+    blog_id bigint REFERENCES blogs ON DELETE CASCADE,
+    message text NOT NULL,
+    read_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_id_idx ON notifications (user_id, id DESC);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    kind text NOT NULL,
+    enabled boolean NOT NULL,
+    PRIMARY KEY (user_id, kind)
+);
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS digest_enabled boolean NOT NULL DEFAULT true;
+
+CREATE TABLE IF NOT EXISTS digest_sends (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    period_start date NOT NULL,
+    status text NOT NULL DEFAULT 'sending',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    sent_at timestamp(0) with time zone,
+    PRIMARY KEY (user_id, period_start)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS subscribers (
+    id bigserial PRIMARY KEY,
+    email text NOT NULL,
+    author_id bigint REFERENCES users ON DELETE CASCADE,
+    confirmed boolean NOT NULL DEFAULT false,
+    token_hash bytea,
+    token_expiry timestamp(0) with time zone,
+    unsubscribe_key text NOT NULL UNIQUE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    confirmed_at timestamp(0) with time zone
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS subscribers_email_author_idx ON subscribers (email, (COALESCE(author_id, 0)));
+CREATE INDEX IF NOT EXISTS subscribers_token_hash_idx ON subscribers (token_hash);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS blog_translations;
+ALTER TABLE blogs DROP COLUMN IF EXISTS locale;
@@ -0,0 +1,12 @@
+ALTER TABLE blogs ADD COLUMN IF NOT EXISTS locale varchar(35) NOT NULL DEFAULT 'en';
+
+CREATE TABLE IF NOT EXISTS blog_translations (
+    blog_id bigint NOT NULL REFERENCES blogs ON DELETE CASCADE,
+    locale varchar(35) NOT NULL,
+    title varchar(200) NOT NULL,
+    content text NOT NULL,
+    slug varchar(200) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (blog_id, locale)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE blogs ADD COLUMN IF NOT EXISTS updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+UPDATE blogs SET updated_at = created_at;
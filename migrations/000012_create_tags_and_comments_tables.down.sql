@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS blogs_user_id_slug_idx;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS blog_tags;
+DROP TABLE IF EXISTS tags;
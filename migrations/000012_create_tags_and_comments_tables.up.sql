@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id bigserial PRIMARY KEY,
+    name varchar(100) NOT NULL,
+    slug varchar(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS blog_tags (
+    blog_id bigint NOT NULL REFERENCES blogs ON DELETE CASCADE,
+    tag_id bigint NOT NULL REFERENCES tags ON DELETE CASCADE,
+    PRIMARY KEY (blog_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id bigserial PRIMARY KEY,
+    blog_id bigint NOT NULL REFERENCES blogs ON DELETE CASCADE,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    author_name varchar(200) NOT NULL,
+    content text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS comments_blog_id_idx ON comments (blog_id, created_at);
+CREATE INDEX IF NOT EXISTS blogs_user_id_slug_idx ON blogs (user_id, slug);
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id bigserial PRIMARY KEY,
+    code text NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS roles_permissions (
+    role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+    permission_id bigint NOT NULL REFERENCES permissions ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS users_roles (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name) VALUES ('author'), ('editor'), ('admin')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO permissions (code) VALUES ('blogs:write'), ('blogs:edit'), ('blogs:moderate'), ('users:admin')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id FROM roles, permissions
+WHERE (roles.name = 'author' AND permissions.code = 'blogs:write')
+OR (roles.name = 'editor' AND permissions.code IN ('blogs:write', 'blogs:edit', 'blogs:moderate'))
+OR roles.name = 'admin'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO users_roles (user_id, role_id)
+SELECT users.id, roles.id FROM users, roles
+WHERE roles.name = 'author'
+ON CONFLICT DO NOTHING;
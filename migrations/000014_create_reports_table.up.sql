@@ -0,0 +1,26 @@
+ALTER TABLE blogs ADD COLUMN IF NOT EXISTS hidden boolean NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS reports (
+    id bigserial PRIMARY KEY,
+    blog_id bigint NOT NULL REFERENCES blogs ON DELETE CASCADE,
+    reporter_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    reason text NOT NULL,
+    status text NOT NULL DEFAULT 'open',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    resolved_at timestamp(0) with time zone,
+    resolved_by bigint REFERENCES users ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS reports_open_blog_reporter_idx ON reports (blog_id, reporter_id) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id bigserial PRIMARY KEY,
+    report_id bigint REFERENCES reports ON DELETE SET NULL,
+    moderator_id bigint REFERENCES users ON DELETE SET NULL,
+    action text NOT NULL,
+    blog_id bigint,
+    target_user_id bigint,
+    note text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS token_revocations (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    family bigint,
+    revoked_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expiry timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS token_revocations_expiry_idx ON token_revocations (expiry);
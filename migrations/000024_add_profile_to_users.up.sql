@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS username text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name text NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio text NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar text NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS website text NOT NULL DEFAULT '';
+
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS account_deletions (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    delete_posts boolean NOT NULL DEFAULT false,
+    requested_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    purge_at timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS account_deletions_purge_at_idx ON account_deletions (purge_at);
+
+CREATE TABLE IF NOT EXISTS data_exports (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    archive bytea NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expiry timestamp(0) with time zone NOT NULL
+);
+
+INSERT INTO users (email, password, activated, suspended, display_name)
+VALUES ('deleted-user@goblog.invalid', '*', false, true, 'Deleted user')
+ON CONFLICT (email) DO NOTHING;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS pending_identities (
+    hash bytea PRIMARY KEY,
+    provider text NOT NULL,
+    subject text NOT NULL,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    email text NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL
+);